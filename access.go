@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTokenIssuer   = "chirpy"
+	defaultTokenAudience = "chirpy-api"
+	defaultTokenLeeway   = 30 * time.Second
+)
+
+func loadTokenConfig() (auth.TokenConfig, error) {
+	keys, err := loadTokenKeys()
+	if err != nil {
+		return auth.TokenConfig{}, err
+	}
+
+	tokenConfig := auth.TokenConfig{
+		Keys:     keys,
+		Issuer:   defaultTokenIssuer,
+		Audience: defaultTokenAudience,
+		Leeway:   defaultTokenLeeway,
+	}
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		tokenConfig.Issuer = issuer
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		tokenConfig.Audience = audience
+	}
+	if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+		tokenConfig.Leeway, err = time.ParseDuration(leeway)
+		if err != nil {
+			return auth.TokenConfig{}, fmt.Errorf("invalid JWT_LEEWAY: %w", err)
+		}
+	}
+	return tokenConfig, nil
+}
+
+// loadAdminEmails reads the comma separated ADMIN_EMAILS list of users who
+// are always treated as admins, whatever their role. It bootstraps the
+// first admin, who can then hand out roles through the admin API.
+func loadAdminEmails() map[string]bool {
+	adminEmails := map[string]bool{}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		email = strings.TrimSpace(email)
+		if email != "" {
+			adminEmails[email] = true
+		}
+	}
+	return adminEmails
+}
+
+// userRole is the role the user acts with. Emails aren't unique regardless
+// of case and anyone can sign up with an address they don't own, so
+// ADMIN_EMAILS only counts for the exact address once it is verified.
+func (cfg *apiConfig) userRole(email string, emailVerified bool, role string) string {
+	if emailVerified && cfg.adminEmails[email] {
+		return auth.RoleAdmin
+	}
+	return role
+}
+
+// userScopes is every scope the user is allowed.
+func (cfg *apiConfig) userScopes(dbUser database.User) []string {
+	scopes := append([]string{}, auth.UserScopes...)
+	if auth.HasRole(cfg.userRole(dbUser.Email, dbUser.EmailVerifiedAt.Valid, dbUser.Role), auth.RoleModerator) {
+		scopes = append(scopes, auth.ScopeUsersAdmin)
+	}
+	return scopes
+}
+
+// makeAccessToken issues an access token carrying every scope the user is
+// allowed and the user's current token version. A token issued in a
+// session is revoked along with it.
+func (cfg *apiConfig) makeAccessToken(dbUser database.User, sessionID uuid.NullUUID, expiresIn time.Duration) (string, error) {
+	return auth.MakeJWT(dbUser.ID, cfg.tokens, expiresIn, cfg.userScopes(dbUser), dbUser.TokenVersion, uuid.NullUUID{}, sessionID)
+}
+
+// validateAccessToken checks an access token and that it has not been
+// revoked, either by a bump of its user's token version since it was
+// issued or by its session being revoked. The user's current role and
+// whether their email is verified are filled in.
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, authToken string) (auth.AccessToken, error) {
+	accessToken, err := auth.ValidateJWT(authToken, cfg.tokens)
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+
+	userAccess, err := cfg.db.GetUserAccess(ctx, accessToken.UserID)
+	if err != nil || userAccess.TokenVersion != accessToken.Version {
+		return auth.AccessToken{}, fmt.Errorf("token has been revoked")
+	}
+	if userAccess.SuspendedAt.Valid {
+		return auth.AccessToken{}, fmt.Errorf("account suspended")
+	}
+	if accessToken.SessionID.Valid {
+		revoked, err := cfg.db.SessionRevoked(ctx, accessToken.SessionID.UUID)
+		if err != nil || revoked {
+			return auth.AccessToken{}, fmt.Errorf("token has been revoked")
+		}
+	}
+	accessToken.Role = cfg.userRole(userAccess.Email, userAccess.EmailVerifiedAt.Valid, userAccess.Role)
+	accessToken.EmailVerified = userAccess.EmailVerifiedAt.Valid
+
+	return accessToken, nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// handleDeleteAccount schedules the caller's account for deletion. The
+// account is only removed once the grace period has passed; logging in
+// again before then cancels the request. Meanwhile the profile is hidden,
+// but the chirps stay up so a cancelled deletion loses nothing; they are
+// taken down when the account is purged.
+func (cfg *apiConfig) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	type requestDeleteAccount struct {
+		Password string `json:"password"`
+	}
+	type responseDeleteAccount struct {
+		DeletionRequestedAt time.Time `json:"deletion_requested_at"`
+		DeleteAfter         time.Time `json:"delete_after"`
+	}
+
+	userID := userIDFromContext(r.Context())
+
+	defer r.Body.Close()
+	var reqDelete requestDeleteAccount
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqDelete)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	err = cfg.verifyPassword(r.Context(), dbUser, reqDelete.Password)
+	if err != nil {
+		respondWithError(w, 403, "password is incorrect")
+		return
+	}
+
+	if !dbUser.DeletionRequestedAt.Valid {
+		dbUser, err = cfg.db.RequestUserDeletion(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+	}
+
+	err = cfg.db.RevokeRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	_, err = cfg.db.BumpTokenVersion(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 202, responseDeleteAccount{
+		DeletionRequestedAt: dbUser.DeletionRequestedAt.Time,
+		DeleteAfter:         dbUser.DeletionRequestedAt.Time.Add(cfg.deletionGracePeriod),
+	})
+}
+
+// handleExportAccount returns everything stored about the caller. The
+// default is a ZIP with one JSON file per section; ?format=json returns
+// the same data as a single document.
+func (cfg *apiConfig) handleExportAccount(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "json" {
+		respondWithError(w, 400, "format must be zip or json")
+		return
+	}
+
+	export, err := cfg.buildAccountExport(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	if format == "json" {
+		respondWithJSON(w, 200, export)
+		return
+	}
+
+	filename := fmt.Sprintf("chirpy-export-%s.zip", export.ExportedAt.Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(200)
+
+	archive := zip.NewWriter(w)
+	for _, section := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"sessions.json", export.Sessions},
+	} {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     section.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			log.Printf("Error writing export for %s: %s", userID, err)
+			return
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(section.data)
+		if err != nil {
+			log.Printf("Error writing export for %s: %s", userID, err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		log.Printf("Error writing export for %s: %s", userID, err)
+	}
+}
+
+func (cfg *apiConfig) buildAccountExport(ctx context.Context, userID uuid.UUID) (AccountExport, error) {
+	dbUser, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	dbChirps, err := cfg.db.GetAllChirpsByAuthor(ctx, userID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	chirps := []Chirp{}
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, dbChirpToChirp(dbChirp))
+	}
+
+	dbRefreshTokens, err := cfg.db.GetRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	sessions := []ExportedSession{}
+	for _, dbRefreshToken := range dbRefreshTokens {
+		sessions = append(sessions, dbRefreshTokenToExportedSession(dbRefreshToken))
+	}
+
+	return AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    dbUserToExportedProfile(dbUser),
+		Chirps:     chirps,
+		Sessions:   sessions,
+	}, nil
+}
+
+// purgeDeletedAccounts deletes accounts whose grace period has run out.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		userIDs, err := cfg.db.ListUsersPendingDeletion(ctx, time.Now().Add(-cfg.deletionGracePeriod))
+		if err != nil {
+			log.Printf("Error purging deleted accounts: %s", err)
+		}
+		purged := 0
+		for _, userID := range userIDs {
+			err = cfg.purgeAccount(ctx, userID)
+			if err != nil {
+				log.Printf("Error purging deleted account %s: %s", userID, err)
+				continue
+			}
+			purged++
+		}
+		if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeAccount deletes the user's chirps the same way they would
+// themselves, so replies and quotes by others keep a tombstone to point
+// at, then scrubs the account. The row stays behind, emptied, as the
+// author of those tombstones. A purge that fails part way is picked up
+// again on the next run.
+func (cfg *apiConfig) purgeAccount(ctx context.Context, userID uuid.UUID) error {
+	dbChirps, err := cfg.db.GetAllChirpsByAuthor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, dbChirp := range dbChirps {
+		if dbChirp.DeletedAt.Valid {
+			continue
+		}
+		// A rechirp of the user's own chirp is gone already if that
+		// chirp came first.
+		_, err = cfg.deleteChirp(ctx, dbChirp.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	return cfg.db.PurgeUser(ctx, userID)
+}
+
+type AccountExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	Profile    ExportedProfile   `json:"profile"`
+	Chirps     []Chirp           `json:"chirps"`
+	Sessions   []ExportedSession `json:"sessions"`
+}
+
+type ExportedProfile struct {
+	Id                  uuid.UUID  `json:"id"`
+	Created_at          time.Time  `json:"created_at"`
+	Updated_at          time.Time  `json:"updated_at"`
+	Email               string     `json:"email"`
+	Handle              string     `json:"handle,omitempty"`
+	DisplayName         string     `json:"display_name"`
+	Bio                 string     `json:"bio"`
+	Location            string     `json:"location"`
+	IsChirpyRed         bool       `json:"is_chirpy_red"`
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+}
+
+// ExportedSession describes a refresh token without exposing the token.
+type ExportedSession struct {
+	SessionID    uuid.UUID  `json:"session_id"`
+	DeviceName   string     `json:"device_name"`
+	UserAgent    string     `json:"user_agent"`
+	IPAddress    string     `json:"ip_address"`
+	Created_at   time.Time  `json:"created_at"`
+	Last_used_at time.Time  `json:"last_used_at"`
+	Expires_at   time.Time  `json:"expires_at"`
+	Revoked_at   *time.Time `json:"revoked_at,omitempty"`
+}
+
+func dbUserToExportedProfile(dbUser database.User) ExportedProfile {
+	profile := ExportedProfile{
+		Id:          dbUser.ID,
+		Created_at:  dbUser.CreatedAt,
+		Updated_at:  dbUser.UpdatedAt,
+		Email:       dbUser.Email,
+		Handle:      dbUser.Handle.String,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		Location:    dbUser.Location,
+		IsChirpyRed: dbUser.IsChirpyRed.Bool,
+	}
+	if dbUser.DeletionRequestedAt.Valid {
+		deletionRequestedAt := dbUser.DeletionRequestedAt.Time
+		profile.DeletionRequestedAt = &deletionRequestedAt
+	}
+	return profile
+}
+
+func dbRefreshTokenToExportedSession(dbRefreshToken database.RefreshToken) ExportedSession {
+	session := ExportedSession{
+		SessionID:    dbRefreshToken.FamilyID,
+		DeviceName:   dbRefreshToken.DeviceName,
+		UserAgent:    dbRefreshToken.UserAgent,
+		IPAddress:    dbRefreshToken.IpAddress,
+		Created_at:   dbRefreshToken.CreatedAt,
+		Last_used_at: dbRefreshToken.LastUsedAt,
+		Expires_at:   dbRefreshToken.ExpiresAt,
+	}
+	if dbRefreshToken.RevokedAt.Valid {
+		revokedAt := dbRefreshToken.RevokedAt.Time
+		session.Revoked_at = &revokedAt
+	}
+	return session
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/KidMuon/chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const maxSuspensionReasonLength = 500
+
+// Actions recorded in the audit log.
+const (
+	auditUserSuspend       = "user.suspend"
+	auditUserUnsuspend     = "user.unsuspend"
+	auditUserPasswordReset = "user.password_reset"
+	auditUserRole          = "user.role"
+	auditUserChirpyRed     = "user.chirpy_red"
+	auditChirpDelete       = "chirp.delete"
+	auditLoginUnlock       = "login.unlock"
+)
+
+// handleAdminGetUsers lists users, newest first unless sort=asc. q matches
+// part of an email, handle or display name; role and suspended narrow the
+// list further.
+func (cfg *apiConfig) handleAdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	listParams := database.ListUsersAscendingParams{
+		PageLimit: pageReq.fetchLimit(),
+	}
+	if search := strings.TrimSpace(query.Get("q")); search != "" {
+		listParams.Search = sql.NullString{String: "%" + escapeLikePattern(search) + "%", Valid: true}
+	}
+	if role := query.Get("role"); role != "" {
+		if !auth.ValidRole(role) {
+			respondWithError(w, 400, fmt.Sprintf("unknown role %q", role))
+			return
+		}
+		listParams.Role = sql.NullString{String: role, Valid: true}
+	}
+	if suspended := query.Get("suspended"); suspended != "" {
+		isSuspended, err := strconv.ParseBool(suspended)
+		if err != nil {
+			respondWithError(w, 400, "suspended must be true or false")
+			return
+		}
+		listParams.Suspended = sql.NullBool{Bool: isSuspended, Valid: true}
+	}
+	descending := true
+	switch query.Get("sort") {
+	case "", "desc":
+	case "asc":
+		descending = false
+	default:
+		respondWithError(w, 400, "sort must be asc or desc")
+		return
+	}
+	if pageReq.cursor != nil {
+		listParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
+
+	// a backward page walks the opposite direction of the requested sort
+	var dbUsers []database.User
+	var err error
+	if descending != pageReq.backward() {
+		dbUsers, err = cfg.db.ListUsersDescending(r.Context(), database.ListUsersDescendingParams(listParams))
+	} else {
+		dbUsers, err = cfg.db.ListUsersAscending(r.Context(), listParams)
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	userPage := paginate(dbUsers, pageReq, func(dbUser database.User) pageCursor {
+		return pageCursor{CreatedAt: dbUser.CreatedAt, ID: dbUser.ID}
+	})
+
+	users := []AdminUser{}
+	for _, dbUser := range userPage.items {
+		users = append(users, cfg.dbUserToAdminUser(dbUser))
+	}
+
+	setLinkHeader(w, r, userPage.nextCursor, userPage.prevCursor)
+	respondWithJSON(w, 200, AdminUserPage{
+		Users:      users,
+		NextCursor: userPage.nextCursor,
+		PrevCursor: userPage.prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handleAdminGetUser(w http.ResponseWriter, r *http.Request) {
+	userID, resErr := getUserIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 404, "user not found")
+		return
+	}
+
+	respondWithJSON(w, 200, cfg.dbUserToAdminUser(dbUser))
+}
+
+// handleSuspendUser locks a user out: they can't log in, and their
+// sessions, access tokens and API keys stop working until they are
+// unsuspended.
+func (cfg *apiConfig) handleSuspendUser(w http.ResponseWriter, r *http.Request) {
+	type requestSuspend struct {
+		Reason string `json:"reason"`
+	}
+
+	defer r.Body.Close()
+	var reqSuspend requestSuspend
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqSuspend)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	reqSuspend.Reason = strings.TrimSpace(reqSuspend.Reason)
+	if reqSuspend.Reason == "" {
+		respondWithError(w, 400, "reason required")
+		return
+	}
+	if len(reqSuspend.Reason) > maxSuspensionReasonLength {
+		respondWithError(w, 400, fmt.Sprintf("reason must be at most %d characters", maxSuspensionReasonLength))
+		return
+	}
+
+	dbUser, resErr := cfg.getManagedUser(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbUser, err = cfg.db.SuspendUser(r.Context(), database.SuspendUserParams{
+		SuspensionReason: reqSuspend.Reason,
+		ID:               dbUser.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	err = cfg.signOutEverywhere(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	cfg.audit(r, auditUserSuspend, userTarget(dbUser.ID), uuid.NullUUID{}, reqSuspend.Reason)
+	respondWithJSON(w, 200, cfg.dbUserToAdminUser(dbUser))
+}
+
+func (cfg *apiConfig) handleUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	dbUser, resErr := cfg.getManagedUser(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbUser, err := cfg.db.UnsuspendUser(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	cfg.audit(r, auditUserUnsuspend, userTarget(dbUser.ID), uuid.NullUUID{}, "")
+	respondWithJSON(w, 200, cfg.dbUserToAdminUser(dbUser))
+}
+
+// handleForcePasswordReset is for accounts that look compromised. The
+// current password stops working, the user is signed out everywhere, and
+// they are emailed a link to choose a new one.
+func (cfg *apiConfig) handleForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	dbUser, resErr := cfg.getManagedUser(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	err := cfg.db.LockUserPassword(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	_, err = cfg.revokeCredentials(r.Context(), dbUser.ID, uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	token, err := cfg.issueUserToken(r.Context(), dbUser, userTokenPasswordReset, passwordResetTokenDuration)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	cfg.sendMail(r.Context(), mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("To keep your Chirpy account safe, we've signed you out and your password "+
+			"has to be reset.\n\n"+
+			"To choose a new password, open this link within the next hour:\n\n%s\n\n"+
+			"If the link has expired, you can ask for a new one from the login page.\n",
+			cfg.appURL("/app/reset-password", token)),
+	})
+
+	cfg.audit(r, auditUserPasswordReset, userTarget(dbUser.ID), uuid.NullUUID{}, "")
+	respondWithJSON(w, 204, nil)
+}
+
+// handleSetUserRole promotes or demotes a user. Their tokens are
+// invalidated so the scopes they hold match the new role.
+func (cfg *apiConfig) handleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	type requestRole struct {
+		Role string `json:"role"`
+	}
+
+	defer r.Body.Close()
+	var reqRole requestRole
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqRole)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+	if !auth.ValidRole(reqRole.Role) {
+		respondWithError(w, 400, fmt.Sprintf("unknown role %q", reqRole.Role))
+		return
+	}
+
+	dbUser, resErr := cfg.getManagedUser(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+	previousRole := dbUser.Role
+
+	dbUser, err = cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{
+		Role: reqRole.Role,
+		ID:   dbUser.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	if previousRole != dbUser.Role {
+		_, err = cfg.db.BumpTokenVersion(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+		cfg.audit(r, auditUserRole, userTarget(dbUser.ID), uuid.NullUUID{}, previousRole+" -> "+dbUser.Role)
+	}
+
+	respondWithJSON(w, 200, cfg.dbUserToAdminUser(dbUser))
+}
+
+// handleGrantChirpyRed upgrades a user without going through Polka, for
+// support cases like a payment the webhook missed.
+func (cfg *apiConfig) handleGrantChirpyRed(w http.ResponseWriter, r *http.Request) {
+	userID, resErr := getUserIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbUser, err := cfg.db.AddChirpyRedByID(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	cfg.audit(r, auditUserChirpyRed, userTarget(dbUser.ID), uuid.NullUUID{}, "")
+	respondWithJSON(w, 200, cfg.dbUserToAdminUser(dbUser))
+}
+
+// handleAdminDeleteChirp takes down a chirp, the same way its author
+// deleting it would. Moderators can't take down chirps by other moderators
+// or admins. An optional reason is kept in the audit log.
+func (cfg *apiConfig) handleAdminDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid chirp id")
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil || dbChirp.DeletedAt.Valid {
+		respondWithError(w, 404, "not found")
+		return
+	}
+
+	accessToken, _ := accessTokenFromContext(r.Context())
+	if dbChirp.UserID != accessToken.UserID {
+		dbAuthor, err := cfg.db.GetUserByID(r.Context(), dbChirp.UserID)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+		resErr := cfg.checkRank(accessToken, dbAuthor)
+		if resErr.err != nil {
+			respondWithError(w, resErr.code, resErr.Error())
+			return
+		}
+	}
+
+	_, err = cfg.deleteChirp(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	cfg.audit(r, auditChirpDelete, userTarget(dbChirp.UserID), uuid.NullUUID{UUID: dbChirp.ID, Valid: true}, reason)
+	respondWithJSON(w, 204, nil)
+}
+
+// handleGetAuditLog lists what has been done through the admin API, newest
+// first, optionally only by actor_id or against target_user_id.
+func (cfg *apiConfig) handleGetAuditLog(w http.ResponseWriter, r *http.Request) {
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	listParams := database.ListAuditLogDescendingParams{
+		PageLimit: pageReq.fetchLimit(),
+	}
+	for _, filter := range []struct {
+		name  string
+		value *uuid.NullUUID
+	}{
+		{"actor_id", &listParams.ActorID},
+		{"target_user_id", &listParams.TargetUserID},
+	} {
+		idString := r.URL.Query().Get(filter.name)
+		if idString == "" {
+			continue
+		}
+		id, err := uuid.Parse(idString)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("invalid %s", filter.name))
+			return
+		}
+		*filter.value = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if pageReq.cursor != nil {
+		listParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
+
+	var dbEntries []database.AuditLog
+	var err error
+	if pageReq.backward() {
+		dbEntries, err = cfg.db.ListAuditLogAscending(r.Context(), database.ListAuditLogAscendingParams(listParams))
+	} else {
+		dbEntries, err = cfg.db.ListAuditLogDescending(r.Context(), listParams)
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	entryPage := paginate(dbEntries, pageReq, func(dbEntry database.AuditLog) pageCursor {
+		return pageCursor{CreatedAt: dbEntry.CreatedAt, ID: dbEntry.ID}
+	})
+
+	entries := []AuditLogEntry{}
+	for _, dbEntry := range entryPage.items {
+		entries = append(entries, dbAuditLogToAuditLogEntry(dbEntry))
+	}
+
+	setLinkHeader(w, r, entryPage.nextCursor, entryPage.prevCursor)
+	respondWithJSON(w, 200, AuditLogPage{
+		Entries:    entries,
+		NextCursor: entryPage.nextCursor,
+		PrevCursor: entryPage.prevCursor,
+	})
+}
+
+// getManagedUser loads the user in the path for an action against them.
+// Staff can't act on themselves, and only on users with a lower role than
+// their own, so a moderator can't suspend another moderator and it takes
+// an admin to change an admin.
+func (cfg *apiConfig) getManagedUser(r *http.Request) (database.User, responseError) {
+	userID, resErr := getUserIDFromPath(r)
+	if resErr.err != nil {
+		return database.User{}, resErr
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		return database.User{}, responseError{code: 404, err: fmt.Errorf("user not found")}
+	}
+	if err != nil {
+		return database.User{}, responseError{code: 500, err: fmt.Errorf("something went wrong")}
+	}
+
+	accessToken, _ := accessTokenFromContext(r.Context())
+	if dbUser.ID == accessToken.UserID {
+		return dbUser, responseError{code: 403, err: fmt.Errorf("cannot manage your own account")}
+	}
+
+	return dbUser, cfg.checkRank(accessToken, dbUser)
+}
+
+// checkRank keeps moderators from acting against users of their own rank
+// or above. Admins can act against anyone.
+func (cfg *apiConfig) checkRank(accessToken auth.AccessToken, dbUser database.User) responseError {
+	targetRole := cfg.userRole(dbUser.Email, dbUser.EmailVerifiedAt.Valid, dbUser.Role)
+	if accessToken.Role != auth.RoleAdmin && auth.HasRole(targetRole, accessToken.Role) {
+		return responseError{code: 403, err: fmt.Errorf("cannot manage a user with the %s role", targetRole)}
+	}
+	return responseError{}
+}
+
+// signOutEverywhere ends every session the user has and invalidates the
+// access tokens already handed out.
+func (cfg *apiConfig) signOutEverywhere(ctx context.Context, userID uuid.UUID) error {
+	err := cfg.db.RevokeRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	_, err = cfg.db.BumpTokenVersion(ctx, userID)
+	return err
+}
+
+// audit records an action taken through the admin API. The action has
+// already happened by now, so a failure is logged rather than reported.
+func (cfg *apiConfig) audit(r *http.Request, action string, targetUserID, targetChirpID uuid.NullUUID, details string) {
+	err := cfg.db.CreateAuditLogEntry(r.Context(), database.CreateAuditLogEntryParams{
+		ActorID:       userIDFromContext(r.Context()),
+		Action:        action,
+		TargetUserID:  targetUserID,
+		TargetChirpID: targetChirpID,
+		Details:       details,
+	})
+	if err != nil {
+		log.Printf("Error recording %s audit log entry: %s", action, err)
+	}
+}
+
+func userTarget(userID uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// escapeLikePattern makes s match itself in an ILIKE pattern.
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+type AdminUser struct {
+	User
+	Suspended_at          *time.Time `json:"suspended_at"`
+	SuspensionReason      string     `json:"suspension_reason,omitempty"`
+	Deletion_requested_at *time.Time `json:"deletion_requested_at"`
+	Deleted_at            *time.Time `json:"deleted_at,omitempty"`
+}
+
+type AdminUserPage struct {
+	Users      []AdminUser `json:"users"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) dbUserToAdminUser(dbUser database.User) AdminUser {
+	adminUser := AdminUser{
+		User:             dbUserToUser(dbUser),
+		SuspensionReason: dbUser.SuspensionReason,
+	}
+	adminUser.Role = cfg.userRole(dbUser.Email, dbUser.EmailVerifiedAt.Valid, dbUser.Role)
+	if dbUser.SuspendedAt.Valid {
+		suspendedAt := dbUser.SuspendedAt.Time
+		adminUser.Suspended_at = &suspendedAt
+	}
+	if dbUser.DeletionRequestedAt.Valid {
+		deletionRequestedAt := dbUser.DeletionRequestedAt.Time
+		adminUser.Deletion_requested_at = &deletionRequestedAt
+	}
+	if dbUser.DeletedAt.Valid {
+		deletedAt := dbUser.DeletedAt.Time
+		adminUser.Deleted_at = &deletedAt
+	}
+	return adminUser
+}
+
+type AuditLogEntry struct {
+	ID              uuid.UUID  `json:"id"`
+	Actor_ID        uuid.UUID  `json:"actor_id"`
+	Action          string     `json:"action"`
+	Target_user_ID  *uuid.UUID `json:"target_user_id,omitempty"`
+	Target_chirp_ID *uuid.UUID `json:"target_chirp_id,omitempty"`
+	Details         string     `json:"details,omitempty"`
+	Created_at      time.Time  `json:"created_at"`
+}
+
+type AuditLogPage struct {
+	Entries    []AuditLogEntry `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+}
+
+func dbAuditLogToAuditLogEntry(dbEntry database.AuditLog) AuditLogEntry {
+	entry := AuditLogEntry{
+		ID:         dbEntry.ID,
+		Actor_ID:   dbEntry.ActorID,
+		Action:     dbEntry.Action,
+		Details:    dbEntry.Details,
+		Created_at: dbEntry.CreatedAt,
+	}
+	if dbEntry.TargetUserID.Valid {
+		targetUserID := dbEntry.TargetUserID.UUID
+		entry.Target_user_ID = &targetUserID
+	}
+	if dbEntry.TargetChirpID.Valid {
+		targetChirpID := dbEntry.TargetChirpID.UUID
+		entry.Target_chirp_ID = &targetChirpID
+	}
+	return entry
+}
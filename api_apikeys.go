@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxAPIKeyNameLength = 100
+
+// handleCreateAPIKey issues a long-lived key for bots and scripts. A key
+// can't be given scopes the caller doesn't have, and without any it gets
+// all of the caller's. The key is only ever shown in this response.
+// Keys outlive sessions and OAuth grants, so only the user, logged in
+// themselves, can create one; an OAuth client or another key can't.
+func (cfg *apiConfig) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	type requestCreateAPIKey struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	accessToken, _ := accessTokenFromContext(r.Context())
+	if !accessToken.FirstParty() {
+		respondWithError(w, 403, "api keys can only be created from a logged in session")
+		return
+	}
+
+	defer r.Body.Close()
+	var reqKey requestCreateAPIKey
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqKey)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	reqKey.Name = strings.TrimSpace(reqKey.Name)
+	if reqKey.Name == "" {
+		respondWithError(w, 400, "name required")
+		return
+	}
+	if len(reqKey.Name) > maxAPIKeyNameLength {
+		respondWithError(w, 400, fmt.Sprintf("name must be at most %d characters", maxAPIKeyNameLength))
+		return
+	}
+
+	if len(reqKey.Scopes) == 0 {
+		reqKey.Scopes = accessToken.Scopes
+	}
+	for _, scope := range reqKey.Scopes {
+		if !accessToken.HasScopes(scope) {
+			respondWithError(w, 403, fmt.Sprintf("cannot grant scope %q", scope))
+			return
+		}
+	}
+
+	var expiresAt sql.NullTime
+	if reqKey.ExpiresAt != nil {
+		if !reqKey.ExpiresAt.After(time.Now()) {
+			respondWithError(w, 400, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: *reqKey.ExpiresAt, Valid: true}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	dbAPIKey, err := cfg.db.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		UserID:    accessToken.UserID,
+		Name:      reqKey.Name,
+		KeyHash:   auth.HashRefreshToken(secret),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(reqKey.Scopes))),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	apiKey := dbAPIKeyToAPIKey(dbAPIKey)
+	apiKey.Key = auth.FormatAPIKey(dbAPIKey.ID, secret)
+	respondWithJSON(w, 201, apiKey)
+}
+
+func (cfg *apiConfig) handleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	dbAPIKeys, err := cfg.db.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	apiKeys := []APIKey{}
+	for _, dbAPIKey := range dbAPIKeys {
+		apiKeys = append(apiKeys, dbAPIKeyToAPIKey(dbAPIKey))
+	}
+
+	respondWithJSON(w, 200, apiKeys)
+}
+
+func (cfg *apiConfig) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid api key id")
+		return
+	}
+
+	revoked, err := cfg.db.RevokeAPIKey(r.Context(), database.RevokeAPIKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "api key not found")
+		return
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+// validateAPIKey checks a user API key and turns it into the access it
+// grants. Keys live outside the token version, so logging out doesn't
+// break a user's bots, but they stop working while the account is pending
+// deletion or suspended and lose any scope the user no longer has.
+func (cfg *apiConfig) validateAPIKey(ctx context.Context, key string) (auth.AccessToken, error) {
+	keyID, secret, err := auth.ParseAPIKey(key)
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+
+	dbAPIKey, err := cfg.db.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return auth.AccessToken{}, fmt.Errorf("invalid api key")
+	}
+	err = auth.CheckRefreshTokenHash(secret, dbAPIKey.KeyHash)
+	if err != nil {
+		return auth.AccessToken{}, fmt.Errorf("invalid api key")
+	}
+	if dbAPIKey.RevokedAt.Valid {
+		return auth.AccessToken{}, fmt.Errorf("api key has been revoked")
+	}
+	if dbAPIKey.ExpiresAt.Valid && dbAPIKey.ExpiresAt.Time.Before(time.Now()) {
+		return auth.AccessToken{}, fmt.Errorf("api key has expired")
+	}
+
+	dbUser, err := cfg.db.GetUserByID(ctx, dbAPIKey.UserID)
+	if err != nil || dbUser.DeletionRequestedAt.Valid {
+		return auth.AccessToken{}, fmt.Errorf("invalid api key")
+	}
+	if dbUser.SuspendedAt.Valid {
+		return auth.AccessToken{}, fmt.Errorf("account suspended")
+	}
+
+	userScopes := cfg.userScopes(dbUser)
+	scopes := []string{}
+	for _, scope := range dbAPIKey.Scopes {
+		if slices.Contains(userScopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	err = cfg.db.TouchAPIKey(ctx, dbAPIKey.ID)
+	if err != nil {
+		log.Printf("Error recording use of api key %s: %s", dbAPIKey.ID, err)
+	}
+
+	return auth.AccessToken{
+		UserID:   dbUser.ID,
+		Scopes:   scopes,
+		Version:  dbUser.TokenVersion,
+		Role:     cfg.userRole(dbUser.Email, dbUser.EmailVerifiedAt.Valid, dbUser.Role),
+		APIKeyID: uuid.NullUUID{UUID: dbAPIKey.ID, Valid: true},
+
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}, nil
+}
+
+type APIKey struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Key          string     `json:"key,omitempty"`
+	Scopes       []string   `json:"scopes"`
+	Created_at   time.Time  `json:"created_at"`
+	Expires_at   *time.Time `json:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at"`
+}
+
+func dbAPIKeyToAPIKey(dbAPIKey database.ApiKey) APIKey {
+	apiKey := APIKey{
+		ID:         dbAPIKey.ID,
+		Name:       dbAPIKey.Name,
+		Scopes:     dbAPIKey.Scopes,
+		Created_at: dbAPIKey.CreatedAt,
+	}
+	if dbAPIKey.ExpiresAt.Valid {
+		expiresAt := dbAPIKey.ExpiresAt.Time
+		apiKey.Expires_at = &expiresAt
+	}
+	if dbAPIKey.LastUsedAt.Valid {
+		lastUsedAt := dbAPIKey.LastUsedAt.Time
+		apiKey.Last_used_at = &lastUsedAt
+	}
+	return apiKey
+}
@@ -2,56 +2,70 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
 	"strings"
 
-	"github.com/KidMuon/chirpy/internal/auth"
 	"github.com/KidMuon/chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) handleGetAllChirps(w http.ResponseWriter, r *http.Request) {
-	var authorIDPresent bool
-
-	authorString := r.URL.Query().Get("author_id")
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
 
-	authorID, err := uuid.Parse(authorString)
-	if err != nil {
-		respondWithError(w, 500, "something went wrong")
+	filter, resErr := getChirpListFilter(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
 		return
-	} else if authorString == "" {
-		authorIDPresent = false
-	} else {
-		authorIDPresent = true
 	}
 
-	dbChirps := []database.Chirp{}
+	listParams := database.ListChirpsAscendingParams{
+		AuthorIds:        filter.authorIDs,
+		ExcludeAuthorIds: filter.excludeAuthorIDs,
+		Since:            filter.since,
+		Until:            filter.until,
+		PageLimit:        pageReq.fetchLimit(),
+	}
+	if pageReq.cursor != nil {
+		listParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
 
-	if !authorIDPresent {
-		allDBChirps, err := cfg.db.GetAllChirps(r.Context())
-		if err != nil {
-			respondWithError(w, 500, "something went wrong")
-			return
-		}
-		dbChirps = append(dbChirps, allDBChirps...)
+	// a backward page walks the opposite direction of the requested sort
+	var dbChirps []database.Chirp
+	var err error
+	if filter.descending != pageReq.backward() {
+		dbChirps, err = cfg.db.ListChirpsDescending(r.Context(), database.ListChirpsDescendingParams(listParams))
 	} else {
-		authorDBChirps, err := cfg.db.GetAllChirpsByAuthor(context.Background(), authorID)
-		if err != nil {
-			respondWithError(w, 500, "something went wrong")
-			return
-		}
-		dbChirps = append(dbChirps, authorDBChirps...)
+		dbChirps, err = cfg.db.ListChirpsAscending(r.Context(), listParams)
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
 	}
 
-	chirps := []Chirp{}
-	for _, dbChrip := range dbChirps {
-		chirps = append(chirps, dbChirpToChirp(dbChrip))
+	chirpPage := paginate(dbChirps, pageReq, dbChirpCursor)
+
+	chirps, err := cfg.dbChirpsToChirps(r.Context(), chirpPage.items, viewerIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
 	}
 
-	respondWithJSON(w, 200, chirps)
+	setLinkHeader(w, r, chirpPage.nextCursor, chirpPage.prevCursor)
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: chirpPage.nextCursor,
+		PrevCursor: chirpPage.prevCursor,
+	})
 }
 
 func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -61,18 +75,27 @@ func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
-	if err != nil {
+	if err != nil || dbChirp.DeletedAt.Valid {
 		respondWithError(w, 404, "not found")
 		return
 	}
-	respondWithJSON(w, 200, dbChirpToChirp(dbChirp))
+
+	chirp, err := cfg.dbChirpToChirpForViewer(r.Context(), dbChirp, viewerIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	respondWithJSON(w, 200, chirp)
 
 }
 
 func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
 	type requestChirp struct {
-		Body    string    `json:"body"`
-		User_ID uuid.UUID `json:"user_id"`
+		Body      string     `json:"body"`
+		User_ID   uuid.UUID  `json:"user_id"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		RechirpOf *uuid.UUID `json:"rechirp_of"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
 	defer r.Body.Close()
@@ -95,18 +118,7 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, 400, err.Error())
-		return
-	}
-
-	token_ID, err := auth.ValidateJWT(token, cfg.tokenSecret)
-	if err != nil {
-		respondWithError(w, 401, err.Error())
-		return
-	}
-	reqChirp.User_ID = token_ID
+	reqChirp.User_ID = userIDFromContext(r.Context())
 
 	chirpToCreate := database.CreateChirpParams{
 		CreatedAt: time.Now(),
@@ -114,35 +126,100 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
 		Body:      getCleanedChirpBody(reqChirp.Body),
 		UserID:    reqChirp.User_ID,
 	}
+
+	if reqChirp.InReplyTo != nil {
+		parentChirp, err := cfg.getReferenceableChirp(r.Context(), *reqChirp.InReplyTo)
+		if err != nil {
+			respondWithError(w, 400, "in_reply_to chirp not found")
+			return
+		}
+		chirpToCreate.InReplyTo = uuid.NullUUID{UUID: parentChirp.ID, Valid: true}
+		chirpToCreate.ThreadID = uuid.NullUUID{UUID: chirpThreadID(parentChirp), Valid: true}
+	}
+
+	switch {
+	case reqChirp.RechirpOf != nil && reqChirp.QuoteOf != nil:
+		respondWithError(w, 400, "a chirp cannot be both a rechirp and a quote")
+		return
+	case reqChirp.RechirpOf != nil:
+		if reqChirp.Body != "" || reqChirp.InReplyTo != nil {
+			respondWithError(w, 400, "a rechirp cannot have a body or be a reply")
+			return
+		}
+		originalChirp, err := cfg.getReferenceableChirp(r.Context(), *reqChirp.RechirpOf)
+		if err != nil {
+			respondWithError(w, 400, "rechirp_of chirp not found")
+			return
+		}
+		chirpToCreate.Kind = chirpKindRechirp
+		chirpToCreate.ReferencedChirpID = uuid.NullUUID{UUID: originalChirp.ID, Valid: true}
+	case reqChirp.QuoteOf != nil:
+		if strings.TrimSpace(reqChirp.Body) == "" {
+			respondWithError(w, 400, "a quote needs a body")
+			return
+		}
+		quotedChirp, err := cfg.getReferenceableChirp(r.Context(), *reqChirp.QuoteOf)
+		if err != nil {
+			respondWithError(w, 400, "quote_of chirp not found")
+			return
+		}
+		chirpToCreate.Kind = chirpKindQuote
+		chirpToCreate.ReferencedChirpID = uuid.NullUUID{UUID: quotedChirp.ID, Valid: true}
+	default:
+		chirpToCreate.Kind = chirpKindChirp
+	}
+
 	dbChirp, err := cfg.db.CreateChirp(r.Context(), chirpToCreate)
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "chirp already rechirped")
+		return
+	}
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
 	}
 
-	respondWithJSON(w, 201, dbChirpToChirp(dbChirp))
-}
-
-func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Request) {
-	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	chirp, err := cfg.dbChirpToChirpForViewer(r.Context(), dbChirp, uuid.NullUUID{UUID: dbChirp.UserID, Valid: true})
 	if err != nil {
 		respondWithError(w, 500, "something went wrong")
 		return
 	}
+	respondWithJSON(w, 201, chirp)
+}
 
-	authToken, err := auth.GetBearerToken(r.Header)
+// getReferenceableChirp loads a chirp that another chirp wants to reply to,
+// rechirp or quote. Rechirps stand in for the chirp they share.
+func (cfg *apiConfig) getReferenceableChirp(ctx context.Context, chirpID uuid.UUID) (database.Chirp, error) {
+	dbChirp, err := cfg.db.GetChirpByID(ctx, chirpID)
 	if err != nil {
-		respondWithError(w, 401, "no authentication found")
-		return
+		return database.Chirp{}, err
+	}
+	if dbChirp.Kind == chirpKindRechirp {
+		if !dbChirp.ReferencedChirpID.Valid {
+			return database.Chirp{}, fmt.Errorf("rechirped chirp no longer exists")
+		}
+		dbChirp, err = cfg.db.GetChirpByID(ctx, dbChirp.ReferencedChirpID.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
 	}
-	userID, err := auth.ValidateJWT(authToken, cfg.tokenSecret)
+	if dbChirp.DeletedAt.Valid {
+		return database.Chirp{}, fmt.Errorf("chirp has been deleted")
+	}
+	return dbChirp, nil
+}
+
+func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, 401, err.Error())
+		respondWithError(w, 500, "something went wrong")
 		return
 	}
 
+	userID := userIDFromContext(r.Context())
+
 	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
-	if err != nil {
+	if err != nil || dbChirp.DeletedAt.Valid {
 		respondWithError(w, 404, "not found")
 		return
 	}
@@ -151,7 +228,7 @@ func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	deletedDbChirp, err := cfg.db.DeleteChirpByID(context.Background(), chirpUUID)
+	deletedDbChirp, err := cfg.deleteChirp(r.Context(), chirpUUID)
 	if err != nil {
 		respondWithError(w, 500, "something went wrong")
 		return
@@ -160,22 +237,272 @@ func (cfg *apiConfig) handleDeleteChirpByID(w http.ResponseWriter, r *http.Reque
 	respondWithJSON(w, 204, dbChirpToChirp(deletedDbChirp))
 }
 
+// deleteChirp removes a chirp. Rechirps only share this chirp so they go
+// with it, while a chirp with replies or quotes is blanked out so the
+// chirps pointing at it keep their context.
+func (cfg *apiConfig) deleteChirp(ctx context.Context, chirpID uuid.UUID) (database.Chirp, error) {
+	err := cfg.db.DeleteRechirpsOf(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	hasDependents, err := cfg.db.ChirpHasDependents(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if hasDependents {
+		return cfg.db.TombstoneChirpByID(ctx, chirpID)
+	}
+	return cfg.db.DeleteChirpByID(ctx, chirpID)
+}
+
+const (
+	chirpKindChirp   = "chirp"
+	chirpKindRechirp = "rechirp"
+	chirpKindQuote   = "quote"
+)
+
 type Chirp struct {
-	ID         uuid.UUID `json:"id"`
-	Created_at time.Time `json:"created_at"`
-	Updated_at time.Time `json:"updated_at"`
-	Body       string    `json:"body"`
-	User_ID    uuid.UUID `json:"user_id"`
+	ID                uuid.UUID  `json:"id"`
+	Created_at        time.Time  `json:"created_at"`
+	Updated_at        time.Time  `json:"updated_at"`
+	Body              string     `json:"body"`
+	User_ID           uuid.UUID  `json:"user_id"`
+	InReplyTo         *uuid.UUID `json:"in_reply_to,omitempty"`
+	ThreadID          uuid.UUID  `json:"thread_id"`
+	Deleted           bool       `json:"deleted,omitempty"`
+	Kind              string     `json:"kind"`
+	ReferencedChirpID *uuid.UUID `json:"referenced_chirp_id,omitempty"`
+	ReferencedChirp   *Chirp     `json:"referenced_chirp,omitempty"`
+	LikeCount         int64      `json:"like_count"`
+	LikedByMe         bool       `json:"liked_by_me"`
+	RechirpCount      int64      `json:"rechirp_count"`
+	QuoteCount        int64      `json:"quote_count"`
+	RechirpedByMe     bool       `json:"rechirped_by_me"`
+}
+
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
 }
 
 func dbChirpToChirp(dbChirp database.Chirp) Chirp {
-	return Chirp{
+	chirp := Chirp{
 		ID:         dbChirp.ID,
 		Created_at: dbChirp.CreatedAt,
 		Updated_at: dbChirp.UpdatedAt,
 		Body:       dbChirp.Body,
 		User_ID:    dbChirp.UserID,
+		ThreadID:   chirpThreadID(dbChirp),
+		Deleted:    dbChirp.DeletedAt.Valid,
+		Kind:       dbChirp.Kind,
+	}
+	if dbChirp.InReplyTo.Valid {
+		inReplyTo := dbChirp.InReplyTo.UUID
+		chirp.InReplyTo = &inReplyTo
+	}
+	if dbChirp.ReferencedChirpID.Valid {
+		referencedChirpID := dbChirp.ReferencedChirpID.UUID
+		chirp.ReferencedChirpID = &referencedChirpID
+	}
+	return chirp
+}
+
+// dbChirpsToChirps converts a batch of chirps for the given viewer. The
+// chirps they rechirp or quote and the stats for all of them are loaded
+// with one query each for the whole batch.
+func (cfg *apiConfig) dbChirpsToChirps(ctx context.Context, dbChirps []database.Chirp, viewerID uuid.NullUUID) ([]Chirp, error) {
+	chirps := []Chirp{}
+	referencedIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, dbChirpToChirp(dbChirp))
+		if dbChirp.ReferencedChirpID.Valid {
+			referencedIDs = append(referencedIDs, dbChirp.ReferencedChirpID.UUID)
+		}
+	}
+
+	referencedChirps := []Chirp{}
+	if len(referencedIDs) > 0 {
+		dbReferencedChirps, err := cfg.db.GetChirpsByIDs(ctx, referencedIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, dbReferencedChirp := range dbReferencedChirps {
+			referencedChirps = append(referencedChirps, dbChirpToChirp(dbReferencedChirp))
+		}
+	}
+
+	allChirps := []*Chirp{}
+	for i := range chirps {
+		allChirps = append(allChirps, &chirps[i])
+	}
+	for i := range referencedChirps {
+		allChirps = append(allChirps, &referencedChirps[i])
+	}
+	err := cfg.addChirpStats(ctx, allChirps, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	referencedByID := make(map[uuid.UUID]*Chirp, len(referencedChirps))
+	for i := range referencedChirps {
+		referencedByID[referencedChirps[i].ID] = &referencedChirps[i]
+	}
+	for i := range chirps {
+		if chirps[i].ReferencedChirpID != nil {
+			chirps[i].ReferencedChirp = referencedByID[*chirps[i].ReferencedChirpID]
+		}
+	}
+
+	return chirps, nil
+}
+
+func (cfg *apiConfig) addChirpStats(ctx context.Context, chirps []*Chirp, viewerID uuid.NullUUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	likeStats, err := cfg.db.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		ViewerID: viewerID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+	rechirpStats, err := cfg.db.GetRechirpStats(ctx, database.GetRechirpStatsParams{
+		ViewerID: viewerID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	likeStatsByChirp := make(map[uuid.UUID]database.GetChirpLikeStatsRow, len(likeStats))
+	for _, stats := range likeStats {
+		likeStatsByChirp[stats.ChirpID] = stats
+	}
+	rechirpStatsByChirp := make(map[uuid.UUID]database.GetRechirpStatsRow, len(rechirpStats))
+	for _, stats := range rechirpStats {
+		rechirpStatsByChirp[stats.ChirpID] = stats
+	}
+	for _, chirp := range chirps {
+		likes := likeStatsByChirp[chirp.ID]
+		chirp.LikeCount = likes.LikeCount
+		chirp.LikedByMe = likes.LikedByMe
+		rechirps := rechirpStatsByChirp[chirp.ID]
+		chirp.RechirpCount = rechirps.RechirpCount
+		chirp.QuoteCount = rechirps.QuoteCount
+		chirp.RechirpedByMe = rechirps.RechirpedByMe
 	}
+
+	return nil
+}
+
+func (cfg *apiConfig) dbChirpToChirpForViewer(ctx context.Context, dbChirp database.Chirp, viewerID uuid.NullUUID) (Chirp, error) {
+	chirps, err := cfg.dbChirpsToChirps(ctx, []database.Chirp{dbChirp}, viewerID)
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirps[0], nil
+}
+
+// chirpThreadID is the root of the conversation a chirp belongs to. Root
+// chirps have no thread_id stored and are their own thread.
+func chirpThreadID(dbChirp database.Chirp) uuid.UUID {
+	if dbChirp.ThreadID.Valid {
+		return dbChirp.ThreadID.UUID
+	}
+	return dbChirp.ID
+}
+
+type chirpListFilter struct {
+	authorIDs        []uuid.UUID
+	excludeAuthorIDs []uuid.UUID
+	since            sql.NullTime
+	until            sql.NullTime
+	descending       bool
+}
+
+func getChirpListFilter(r *http.Request) (chirpListFilter, responseError) {
+	query := r.URL.Query()
+	var filter chirpListFilter
+	var err error
+
+	filter.authorIDs, err = parseUUIDList(query["author_id"])
+	if err != nil {
+		return chirpListFilter{}, responseError{code: 400, err: fmt.Errorf("invalid author_id")}
+	}
+
+	filter.excludeAuthorIDs, err = parseUUIDList(query["exclude_author"])
+	if err != nil {
+		return chirpListFilter{}, responseError{code: 400, err: fmt.Errorf("invalid exclude_author")}
+	}
+
+	filter.since, err = parseTimeParam(query.Get("since"))
+	if err != nil {
+		return chirpListFilter{}, responseError{code: 400, err: fmt.Errorf("since must be an RFC 3339 timestamp")}
+	}
+
+	filter.until, err = parseTimeParam(query.Get("until"))
+	if err != nil {
+		return chirpListFilter{}, responseError{code: 400, err: fmt.Errorf("until must be an RFC 3339 timestamp")}
+	}
+
+	if filter.since.Valid && filter.until.Valid && !filter.since.Time.Before(filter.until.Time) {
+		return chirpListFilter{}, responseError{code: 400, err: fmt.Errorf("since must be before until")}
+	}
+
+	switch query.Get("sort") {
+	case "", "asc":
+		filter.descending = false
+	case "desc":
+		filter.descending = true
+	default:
+		return chirpListFilter{}, responseError{code: 400, err: fmt.Errorf("sort must be asc or desc")}
+	}
+
+	return filter, responseError{}
+}
+
+// parseUUIDList accepts both repeated parameters and comma separated values.
+// It never returns nil so the result can be passed straight to an array query.
+func parseUUIDList(values []string) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for _, value := range values {
+		for _, idString := range strings.Split(value, ",") {
+			idString = strings.TrimSpace(idString)
+			if idString == "" {
+				continue
+			}
+			id, err := uuid.Parse(idString)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func parseTimeParam(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	parsedTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: parsedTime.UTC(), Valid: true}, nil
+}
+
+func dbChirpCursor(dbChirp database.Chirp) pageCursor {
+	return pageCursor{CreatedAt: dbChirp.CreatedAt, ID: dbChirp.ID}
 }
 
 func getCleanedChirpBody(chirpBody string) string {
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleFollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg.updateFollow(w, r, true)
+}
+
+func (cfg *apiConfig) handleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg.updateFollow(w, r, false)
+}
+
+func (cfg *apiConfig) updateFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	followeeID, resErr := getUserIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	userID := userIDFromContext(r.Context())
+
+	if followeeID == userID {
+		respondWithError(w, 400, "cannot follow yourself")
+		return
+	}
+
+	followee, err := cfg.db.GetUserByID(r.Context(), followeeID)
+	if err != nil || (follow && followee.DeletedAt.Valid) {
+		respondWithError(w, 404, "user not found")
+		return
+	}
+
+	if follow {
+		err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+			CreatedAt:  time.Now(),
+		})
+	} else {
+		err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *apiConfig) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, true)
+}
+
+func (cfg *apiConfig) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, false)
+}
+
+// listFollows pages through either side of a user's follow graph, newest
+// follows first.
+func (cfg *apiConfig) listFollows(w http.ResponseWriter, r *http.Request, followers bool) {
+	userID, resErr := getUserIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	_, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 404, "user not found")
+		return
+	}
+
+	listParams := database.ListFollowersDescendingParams{
+		UserID:    userID,
+		PageLimit: pageReq.fetchLimit(),
+	}
+	if pageReq.cursor != nil {
+		listParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
+
+	var rows []database.ListFollowersDescendingRow
+	switch {
+	case followers && pageReq.backward():
+		var ascendingRows []database.ListFollowersAscendingRow
+		ascendingRows, err = cfg.db.ListFollowersAscending(r.Context(), database.ListFollowersAscendingParams(listParams))
+		for _, row := range ascendingRows {
+			rows = append(rows, database.ListFollowersDescendingRow(row))
+		}
+	case followers:
+		rows, err = cfg.db.ListFollowersDescending(r.Context(), listParams)
+	case pageReq.backward():
+		var ascendingRows []database.ListFollowingAscendingRow
+		ascendingRows, err = cfg.db.ListFollowingAscending(r.Context(), database.ListFollowingAscendingParams(listParams))
+		for _, row := range ascendingRows {
+			rows = append(rows, database.ListFollowersDescendingRow(row))
+		}
+	default:
+		var followingRows []database.ListFollowingDescendingRow
+		followingRows, err = cfg.db.ListFollowingDescending(r.Context(), database.ListFollowingDescendingParams(listParams))
+		for _, row := range followingRows {
+			rows = append(rows, database.ListFollowersDescendingRow(row))
+		}
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	followPage := paginate(rows, pageReq, func(row database.ListFollowersDescendingRow) pageCursor {
+		return pageCursor{CreatedAt: row.FollowedAt, ID: row.User.ID}
+	})
+
+	follows := []Follow{}
+	for _, row := range followPage.items {
+		follows = append(follows, Follow{
+			PublicProfile: dbUserToPublicProfile(row.User),
+			Followed_at:   row.FollowedAt,
+		})
+	}
+
+	setLinkHeader(w, r, followPage.nextCursor, followPage.prevCursor)
+	respondWithJSON(w, 200, FollowPage{
+		Users:      follows,
+		NextCursor: followPage.nextCursor,
+		PrevCursor: followPage.prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	listParams := database.ListTimelineDescendingParams{
+		UserID:    userID,
+		PageLimit: pageReq.fetchLimit(),
+	}
+	if pageReq.cursor != nil {
+		listParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
+
+	var dbChirps []database.Chirp
+	var err error
+	if pageReq.backward() {
+		dbChirps, err = cfg.db.ListTimelineAscending(r.Context(), database.ListTimelineAscendingParams(listParams))
+	} else {
+		dbChirps, err = cfg.db.ListTimelineDescending(r.Context(), listParams)
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	timelinePage := paginate(dbChirps, pageReq, dbChirpCursor)
+
+	chirps, err := cfg.dbChirpsToChirps(r.Context(), timelinePage.items, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	setLinkHeader(w, r, timelinePage.nextCursor, timelinePage.prevCursor)
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: timelinePage.nextCursor,
+		PrevCursor: timelinePage.prevCursor,
+	})
+}
+
+func getUserIDFromPath(r *http.Request) (uuid.UUID, responseError) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		return uuid.UUID{}, responseError{code: 400, err: fmt.Errorf("invalid user id")}
+	}
+	return userID, responseError{}
+}
+
+type Follow struct {
+	PublicProfile
+	Followed_at time.Time `json:"followed_at"`
+}
+
+type FollowPage struct {
+	Users      []Follow `json:"users"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleLikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.updateChirpLike(w, r, true)
+}
+
+func (cfg *apiConfig) handleUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.updateChirpLike(w, r, false)
+}
+
+// updateChirpLike likes or unlikes a chirp. Liking a rechirp likes the
+// chirp it shares, so likes aren't split between the copies.
+func (cfg *apiConfig) updateChirpLike(w http.ResponseWriter, r *http.Request, liked bool) {
+	chirpUUID, resErr := getChirpIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	userID := userIDFromContext(r.Context())
+
+	dbChirp, err := cfg.getReferenceableChirp(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, 404, "not found")
+		return
+	}
+
+	if liked {
+		err = cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+			ChirpID:   dbChirp.ID,
+			UserID:    userID,
+			CreatedAt: time.Now(),
+		})
+	} else {
+		err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+			ChirpID: dbChirp.ID,
+			UserID:  userID,
+		})
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	chirp, err := cfg.dbChirpToChirpForViewer(r.Context(), dbChirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	respondWithJSON(w, 200, chirp)
+}
+
+// handleGetChirpLikes pages through the users who liked a chirp, newest
+// likes first. A rechirp lists the likes of the chirp it shares.
+func (cfg *apiConfig) handleGetChirpLikes(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, resErr := getChirpIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbChirp, err := cfg.getReferenceableChirp(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, 404, "not found")
+		return
+	}
+
+	listParams := database.ListChirpLikersDescendingParams{
+		ChirpID:   dbChirp.ID,
+		PageLimit: pageReq.fetchLimit(),
+	}
+	if pageReq.cursor != nil {
+		listParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
+
+	var rows []database.ListChirpLikersDescendingRow
+	if pageReq.backward() {
+		var ascendingRows []database.ListChirpLikersAscendingRow
+		ascendingRows, err = cfg.db.ListChirpLikersAscending(r.Context(), database.ListChirpLikersAscendingParams(listParams))
+		for _, row := range ascendingRows {
+			rows = append(rows, database.ListChirpLikersDescendingRow(row))
+		}
+	} else {
+		rows, err = cfg.db.ListChirpLikersDescending(r.Context(), listParams)
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	likePage := paginate(rows, pageReq, func(row database.ListChirpLikersDescendingRow) pageCursor {
+		return pageCursor{CreatedAt: row.LikedAt, ID: row.User.ID}
+	})
+
+	likes := []ChirpLike{}
+	for _, row := range likePage.items {
+		likes = append(likes, ChirpLike{
+			PublicProfile: dbUserToPublicProfile(row.User),
+			Liked_at:      row.LikedAt,
+		})
+	}
+
+	setLinkHeader(w, r, likePage.nextCursor, likePage.prevCursor)
+	respondWithJSON(w, 200, ChirpLikePage{
+		Users:      likes,
+		NextCursor: likePage.nextCursor,
+		PrevCursor: likePage.prevCursor,
+	})
+}
+
+type ChirpLike struct {
+	PublicProfile
+	Liked_at time.Time `json:"liked_at"`
+}
+
+type ChirpLikePage struct {
+	Users      []ChirpLike `json:"users"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer        = "Chirpy"
+	mfaTokenDuration  = 5 * time.Minute
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+// loadMFAKey reads the AES-256 key TOTP secrets are encrypted with from
+// MFA_ENCRYPTION_KEY, base64 encoded. Without it two-factor authentication
+// can't be enabled.
+func loadMFAKey() ([]byte, error) {
+	encodedKey := os.Getenv("MFA_ENCRYPTION_KEY")
+	if encodedKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY must be 32 bytes, base64 encoded")
+	}
+	return key, nil
+}
+
+// issueMFAChallenge hands out the challenge token between the password
+// and second factor steps of a login. It works once, for mfaMaxAttempts
+// codes at most, and stops working if the user's tokens are revoked. Like
+// refresh tokens, only the id and a hash of the secret are stored.
+func (cfg *apiConfig) issueMFAChallenge(ctx context.Context, dbUser database.User) (string, error) {
+	err := cfg.db.DeleteStaleMFAChallenges(ctx, dbUser.ID)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	challengeID := uuid.New()
+	err = cfg.db.CreateMFAChallenge(ctx, database.CreateMFAChallengeParams{
+		ID:           challengeID,
+		UserID:       dbUser.ID,
+		TokenHash:    auth.HashRefreshToken(secret),
+		TokenVersion: dbUser.TokenVersion,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(mfaTokenDuration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return auth.FormatRefreshToken(challengeID, secret), nil
+}
+
+// findMFAChallenge looks a challenge token up and checks its secret. Used
+// and expired challenges are still returned.
+func (cfg *apiConfig) findMFAChallenge(ctx context.Context, mfaToken string) (database.MfaChallenge, error) {
+	challengeID, secret, err := auth.ParseRefreshToken(mfaToken)
+	if err != nil {
+		return database.MfaChallenge{}, err
+	}
+
+	dbChallenge, err := cfg.db.GetMFAChallenge(ctx, challengeID)
+	if err != nil {
+		return database.MfaChallenge{}, err
+	}
+
+	err = auth.CheckRefreshTokenHash(secret, dbChallenge.TokenHash)
+	if err != nil {
+		return database.MfaChallenge{}, err
+	}
+
+	return dbChallenge, nil
+}
+
+// handleEnrollTOTP starts enrolling an authenticator app. Two-factor
+// authentication isn't turned on until a code from the app is verified.
+func (cfg *apiConfig) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	type requestEnroll struct {
+		Password string `json:"password"`
+	}
+
+	if cfg.mfaKey == nil {
+		respondWithError(w, 503, "two-factor authentication is not configured")
+		return
+	}
+
+	userID := userIDFromContext(r.Context())
+
+	defer r.Body.Close()
+	var reqEnroll requestEnroll
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqEnroll)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	err = cfg.verifyPassword(r.Context(), dbUser, reqEnroll.Password)
+	if err != nil {
+		respondWithError(w, 403, "password is incorrect")
+		return
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.MakeTOTPSecret()
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	encryptedSecret, err := auth.EncryptSecret(cfg.mfaKey, secret)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	err = cfg.db.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		TotpSecret: encryptedSecret,
+		ID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	recoveryCodes, err := cfg.replaceRecoveryCodes(r, dbUser)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, TOTPEnrollment{
+		Secret:        secret,
+		OTPAuthURI:    auth.TOTPURI(totpIssuer, dbUser.Email, secret),
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// handleVerifyTOTP finishes enrollment by checking a code from the app.
+func (cfg *apiConfig) handleVerifyTOTP(w http.ResponseWriter, r *http.Request) {
+	type requestVerify struct {
+		Code string `json:"code"`
+	}
+
+	userID := userIDFromContext(r.Context())
+
+	defer r.Body.Close()
+	var reqVerify requestVerify
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqVerify)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+	if !dbUser.TotpSecret.Valid {
+		respondWithError(w, 400, "two-factor enrollment has not been started")
+		return
+	}
+
+	counter, resErr := cfg.checkTOTPCode(dbUser, reqVerify.Code)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbUser, err = cfg.db.EnableTOTP(r.Context(), database.EnableTOTPParams{
+		TotpLastCounter: counter,
+		ID:              userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, dbUserToUser(dbUser))
+}
+
+// handleDisableTOTP turns two-factor authentication off. It needs both the
+// password and a current code or recovery code.
+func (cfg *apiConfig) handleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	type requestDisable struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	userID := userIDFromContext(r.Context())
+
+	defer r.Body.Close()
+	var reqDisable requestDisable
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqDisable)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	err = cfg.verifyPassword(r.Context(), dbUser, reqDisable.Password)
+	if err != nil {
+		respondWithError(w, 403, "password is incorrect")
+		return
+	}
+
+	if !dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, 409, "two-factor authentication is not enabled")
+		return
+	}
+
+	resErr := cfg.verifySecondFactor(r, dbUser, reqDisable.Code, reqDisable.RecoveryCode)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	err = cfg.db.DisableTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	err = cfg.db.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+// handleLoginMFA is the second step of logging in with two-factor
+// authentication: it trades the challenge token from /api/login and a code
+// for the usual access and refresh tokens. The challenge token can only be
+// traded once.
+func (cfg *apiConfig) handleLoginMFA(w http.ResponseWriter, r *http.Request) {
+	type requestLoginMFA struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		DeviceName   string `json:"device_name"`
+	}
+
+	defer r.Body.Close()
+	var reqLogin requestLoginMFA
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqLogin)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	dbChallenge, err := cfg.findMFAChallenge(r.Context(), reqLogin.MFAToken)
+	if err != nil {
+		respondWithError(w, 401, "invalid or expired mfa token")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), dbChallenge.UserID)
+	if err != nil || dbUser.TokenVersion != dbChallenge.TokenVersion || !dbUser.TotpEnabledAt.Valid {
+		respondWithError(w, 401, "invalid or expired mfa token")
+		return
+	}
+
+	if !cfg.checkLoginThrottle(w, r, dbUser.Email) {
+		return
+	}
+
+	// Every guess is counted before it is checked, so concurrent requests
+	// can't get past the limit.
+	counted, err := cfg.db.AddMFAChallengeAttempt(r.Context(), database.AddMFAChallengeAttemptParams{
+		ID:          dbChallenge.ID,
+		MaxAttempts: mfaMaxAttempts,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	if counted == 0 {
+		respondWithError(w, 401, "invalid or expired mfa token")
+		return
+	}
+
+	resErr := cfg.verifySecondFactor(r, dbUser, reqLogin.Code, reqLogin.RecoveryCode)
+	if resErr.err != nil {
+		if resErr.code == 401 {
+			cfg.recordLoginFailure(r, dbUser.Email)
+		}
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	used, err := cfg.db.UseMFAChallenge(r.Context(), dbChallenge.ID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	if used == 0 {
+		respondWithError(w, 401, "invalid or expired mfa token")
+		return
+	}
+
+	cfg.recordLoginSuccess(r, dbUser.Email)
+	cfg.completeLogin(w, r, dbUser, reqLogin.DeviceName, time.Duration(3600*1e9))
+}
+
+// verifySecondFactor accepts either a TOTP code, which can only be used
+// once, or an unused recovery code, which is used up.
+func (cfg *apiConfig) verifySecondFactor(r *http.Request, dbUser database.User, code, recoveryCode string) responseError {
+	if code != "" {
+		counter, resErr := cfg.checkTOTPCode(dbUser, code)
+		if resErr.err != nil {
+			return resErr
+		}
+		used, err := cfg.db.UseTOTPCounter(r.Context(), database.UseTOTPCounterParams{
+			TotpLastCounter: counter,
+			ID:              dbUser.ID,
+		})
+		if err != nil {
+			return responseError{code: 500, err: fmt.Errorf("something went wrong")}
+		}
+		if used == 0 {
+			return responseError{code: 401, err: fmt.Errorf("code has already been used")}
+		}
+		return responseError{}
+	}
+
+	if recoveryCode != "" {
+		dbRecoveryCodes, err := cfg.db.GetUnusedRecoveryCodes(r.Context(), dbUser.ID)
+		if err != nil {
+			return responseError{code: 500, err: fmt.Errorf("something went wrong")}
+		}
+		recoveryCode = auth.NormalizeRecoveryCode(recoveryCode)
+		for _, dbRecoveryCode := range dbRecoveryCodes {
+			if auth.CheckPasswordHash(recoveryCode, dbRecoveryCode.CodeHash) != nil {
+				continue
+			}
+			used, err := cfg.db.UseRecoveryCode(r.Context(), dbRecoveryCode.ID)
+			if err != nil {
+				return responseError{code: 500, err: fmt.Errorf("something went wrong")}
+			}
+			if used == 1 {
+				return responseError{}
+			}
+		}
+		return responseError{code: 401, err: fmt.Errorf("incorrect recovery code")}
+	}
+
+	return responseError{code: 400, err: fmt.Errorf("code or recovery_code required")}
+}
+
+func (cfg *apiConfig) checkTOTPCode(dbUser database.User, code string) (int64, responseError) {
+	if cfg.mfaKey == nil {
+		return 0, responseError{code: 503, err: fmt.Errorf("two-factor authentication is not configured")}
+	}
+
+	secret, err := auth.DecryptSecret(cfg.mfaKey, dbUser.TotpSecret.String)
+	if err != nil {
+		return 0, responseError{code: 500, err: fmt.Errorf("something went wrong")}
+	}
+
+	counter, err := auth.ValidateTOTP(secret, code, time.Now())
+	if err != nil {
+		return 0, responseError{code: 401, err: err}
+	}
+	return counter, responseError{}
+}
+
+// replaceRecoveryCodes issues a fresh set of recovery codes, invalidating
+// any the user had. Only their hashes are stored, so this is the only time
+// the codes can be shown.
+func (cfg *apiConfig) replaceRecoveryCodes(r *http.Request, dbUser database.User) ([]string, error) {
+	err := cfg.db.DeleteRecoveryCodes(r.Context(), dbUser.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := []string{}
+	for i := 0; i < recoveryCodeCount; i++ {
+		recoveryCode, err := auth.MakeRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codeHash, err := auth.HashPassword(recoveryCode)
+		if err != nil {
+			return nil, err
+		}
+		err = cfg.db.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   dbUser.ID,
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			return nil, err
+		}
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+	}
+	return recoveryCodes, nil
+}
+
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	authorizationCodeDuration = time.Minute
+	oauthAccessTokenDuration  = time.Hour
+)
+
+// oauthError is an error as the OAuth 2.0 spec reports them: a fixed code
+// the client can act on and a description for its developers.
+type oauthError struct {
+	code        string
+	description string
+}
+
+// authorizationRequest is a checked request to /oauth/authorize.
+type authorizationRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+// parseAuthorizationRequest checks the parameters of an authorization
+// request. Until the client and redirect URI have checked out there is
+// nowhere safe to send an error, so redirectURI is left empty and the
+// error is shown to the user instead.
+func (cfg *apiConfig) parseAuthorizationRequest(r *http.Request, params url.Values) (authorizationRequest, oauthError) {
+	authRequest := authorizationRequest{}
+
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		return authRequest, oauthError{"invalid_request", "client_id is missing or invalid"}
+	}
+	authRequest.client, err = cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return authRequest, oauthError{"invalid_request", "unknown client"}
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(authRequest.client.RedirectUris) == 1 {
+		redirectURI = authRequest.client.RedirectUris[0]
+	}
+	if !slices.Contains(authRequest.client.RedirectUris, redirectURI) {
+		return authRequest, oauthError{"invalid_request", "redirect_uri is not registered for this client"}
+	}
+	authRequest.redirectURI = redirectURI
+	authRequest.state = params.Get("state")
+
+	if params.Get("response_type") != "code" {
+		return authRequest, oauthError{"unsupported_response_type", "response_type must be code"}
+	}
+
+	authRequest.codeChallenge = params.Get("code_challenge")
+	if authRequest.codeChallenge == "" {
+		return authRequest, oauthError{"invalid_request", "code_challenge is required"}
+	}
+	if params.Get("code_challenge_method") != auth.PKCEMethodS256 {
+		return authRequest, oauthError{"invalid_request", "code_challenge_method must be S256"}
+	}
+	err = auth.ValidateCodeChallenge(authRequest.codeChallenge)
+	if err != nil {
+		return authRequest, oauthError{"invalid_request", err.Error()}
+	}
+
+	authRequest.scopes = strings.Fields(params.Get("scope"))
+	if len(authRequest.scopes) == 0 {
+		authRequest.scopes = authRequest.client.Scopes
+	}
+	for _, scope := range authRequest.scopes {
+		if !slices.Contains(authRequest.client.Scopes, scope) {
+			return authRequest, oauthError{"invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", scope)}
+		}
+	}
+	authRequest.scopes = slices.Compact(slices.Sorted(slices.Values(authRequest.scopes)))
+
+	return authRequest, oauthError{}
+}
+
+// handleAuthorize starts the authorization code flow by showing the
+// consent screen.
+func (cfg *apiConfig) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	authRequest, oauthErr := cfg.parseAuthorizationRequest(r, r.URL.Query())
+	if oauthErr.code != "" {
+		failAuthorization(w, r, authRequest, oauthErr)
+		return
+	}
+
+	renderConsent(w, 200, authRequest, "", "")
+}
+
+// handleAuthorizeConsent handles the consent form. The user logs in on the
+// form itself, so their password only ever goes to Chirpy; if they allow
+// the request the client is sent back an authorization code.
+func (cfg *apiConfig) handleAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		renderAuthorizeError(w, 400, "malformed request")
+		return
+	}
+
+	authRequest, oauthErr := cfg.parseAuthorizationRequest(r, r.PostForm)
+	if oauthErr.code != "" {
+		failAuthorization(w, r, authRequest, oauthErr)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		failAuthorization(w, r, authRequest, oauthError{"access_denied", "the user denied the request"})
+		return
+	}
+
+	email := strings.TrimSpace(r.PostForm.Get("email"))
+	dbUser, resErr := cfg.authenticateForConsent(r, email, r.PostForm.Get("password"), strings.TrimSpace(r.PostForm.Get("code")))
+	if resErr.err != nil {
+		if resErr.code == 500 {
+			renderAuthorizeError(w, 500, "something went wrong")
+			return
+		}
+		renderConsent(w, resErr.code, authRequest, email, resErr.Error())
+		return
+	}
+
+	if dbUser.DeletionRequestedAt.Valid {
+		err = cfg.db.CancelUserDeletion(r.Context(), dbUser.ID)
+		if err != nil {
+			renderAuthorizeError(w, 500, "something went wrong")
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		renderAuthorizeError(w, 500, "something went wrong")
+		return
+	}
+	codeID := uuid.New()
+	err = cfg.db.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		ID:            codeID,
+		CodeHash:      auth.HashRefreshToken(secret),
+		ClientID:      authRequest.client.ID,
+		UserID:        dbUser.ID,
+		RedirectUri:   authRequest.redirectURI,
+		Scope:         strings.Join(authRequest.scopes, " "),
+		CodeChallenge: authRequest.codeChallenge,
+		CreatedAt:     time.Now(),
+		ExpiresAt:     time.Now().Add(authorizationCodeDuration),
+	})
+	if err != nil {
+		renderAuthorizeError(w, 500, "something went wrong")
+		return
+	}
+
+	redirectToClient(w, r, authRequest.redirectURI, url.Values{
+		"code":  {auth.FormatRefreshToken(codeID, secret)},
+		"state": {authRequest.state},
+	})
+}
+
+// authenticateForConsent checks the credentials entered on the consent
+// screen the same way /api/login and /api/login/mfa do, counting failures
+// against the same lockouts.
+func (cfg *apiConfig) authenticateForConsent(r *http.Request, email, password, code string) (database.User, responseError) {
+	wait, err := cfg.loginThrottleWait(r, email)
+	if err != nil {
+		return database.User{}, responseError{code: 500, err: fmt.Errorf("something went wrong")}
+	}
+	if wait > 0 {
+		return database.User{}, responseError{code: 429, err: fmt.Errorf("too many failed login attempts, try again later")}
+	}
+
+	dbUser, err := cfg.db.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		cfg.recordLoginFailure(r, email)
+		return database.User{}, responseError{code: 401, err: fmt.Errorf("incorrect email or password")}
+	}
+
+	err = cfg.verifyPassword(r.Context(), dbUser, password)
+	if err != nil {
+		cfg.recordLoginFailure(r, email)
+		return database.User{}, responseError{code: 401, err: fmt.Errorf("incorrect email or password")}
+	}
+
+	if dbUser.TotpEnabledAt.Valid {
+		if code == "" {
+			return database.User{}, responseError{code: 401, err: fmt.Errorf("enter the code from your authenticator app or a recovery code")}
+		}
+
+		// The form has one field for both kinds of code; recovery codes
+		// are the longer ones.
+		totpCode, recoveryCode := code, ""
+		if len(code) > 6 {
+			totpCode, recoveryCode = "", code
+		}
+		resErr := cfg.verifySecondFactor(r, dbUser, totpCode, recoveryCode)
+		if resErr.err != nil {
+			if resErr.code == 401 {
+				cfg.recordLoginFailure(r, email)
+			}
+			return database.User{}, resErr
+		}
+	}
+
+	cfg.recordLoginSuccess(r, email)
+
+	if dbUser.SuspendedAt.Valid {
+		return database.User{}, responseError{code: 403, err: fmt.Errorf("account suspended")}
+	}
+	return dbUser, responseError{}
+}
+
+// failAuthorization sends an error back to the client if its redirect URI
+// has been checked, and otherwise shows it to the user.
+func failAuthorization(w http.ResponseWriter, r *http.Request, authRequest authorizationRequest, oauthErr oauthError) {
+	if authRequest.redirectURI == "" {
+		renderAuthorizeError(w, 400, oauthErr.description)
+		return
+	}
+
+	redirectToClient(w, r, authRequest.redirectURI, url.Values{
+		"error":             {oauthErr.code},
+		"error_description": {oauthErr.description},
+		"state":             {authRequest.state},
+	})
+}
+
+// redirectToClient adds params to the query of a registered redirect URI,
+// leaving out empty ones, and sends the user there.
+func redirectToClient(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		renderAuthorizeError(w, 500, "something went wrong")
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		if values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+// handleOAuthToken is the token endpoint. Clients trade an authorization
+// code, or later a refresh token, for an access token limited to the
+// scopes the user granted.
+func (cfg *apiConfig) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, 400, oauthError{"invalid_request", "malformed request"})
+		return
+	}
+
+	dbClient, oauthErr := cfg.authenticateOAuthClient(r)
+	if oauthErr.code != "" {
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, 401, oauthErr)
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.grantAuthorizationCode(w, r, dbClient)
+	case "refresh_token":
+		cfg.grantRefreshToken(w, r, dbClient)
+	default:
+		respondWithOAuthError(w, 400, oauthError{"unsupported_grant_type", "grant_type must be authorization_code or refresh_token"})
+	}
+}
+
+// authenticateOAuthClient identifies the client from HTTP Basic auth or
+// the form. Confidential clients must prove themselves with their secret;
+// public clients must not send one.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, oauthError) {
+	clientIDString, secret, ok := r.BasicAuth()
+	if ok {
+		clientIDString, _ = url.QueryUnescape(clientIDString)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientIDString = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(clientIDString)
+	if err != nil {
+		return database.OauthClient{}, oauthError{"invalid_client", "client authentication failed"}
+	}
+	dbClient, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, oauthError{"invalid_client", "client authentication failed"}
+	}
+
+	if dbClient.SecretHash.Valid {
+		err = auth.CheckRefreshTokenHash(secret, dbClient.SecretHash.String)
+	} else if secret != "" {
+		err = fmt.Errorf("public clients have no secret")
+	}
+	if err != nil {
+		return database.OauthClient{}, oauthError{"invalid_client", "client authentication failed"}
+	}
+
+	return dbClient, oauthError{}
+}
+
+// grantAuthorizationCode redeems an authorization code. A code can only be
+// redeemed once; if it turns up again, the tokens issued for it are
+// revoked, since either it or they may have leaked.
+func (cfg *apiConfig) grantAuthorizationCode(w http.ResponseWriter, r *http.Request, dbClient database.OauthClient) {
+	invalidGrant := oauthError{"invalid_grant", "authorization code is invalid or expired"}
+
+	codeID, secret, err := auth.ParseRefreshToken(r.PostForm.Get("code"))
+	if err != nil {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+	dbCode, err := cfg.db.GetAuthorizationCode(r.Context(), codeID)
+	if err != nil {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+	err = auth.CheckRefreshTokenHash(secret, dbCode.CodeHash)
+	if err != nil || dbCode.ClientID != dbClient.ID {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	if dbCode.UsedAt.Valid {
+		cfg.revokeReusedAuthorizationCode(r.Context(), dbCode)
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	if r.PostForm.Get("redirect_uri") != dbCode.RedirectUri {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "redirect_uri does not match the authorization request"})
+		return
+	}
+
+	err = auth.VerifyPKCE(r.PostForm.Get("code_verifier"), dbCode.CodeChallenge)
+	if err != nil {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", err.Error()})
+		return
+	}
+
+	used, err := cfg.db.UseAuthorizationCode(r.Context(), dbCode.ID)
+	if err != nil {
+		respondWithOAuthError(w, 500, oauthError{"server_error", "something went wrong"})
+		return
+	}
+	if used == 0 {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), dbCode.UserID)
+	if err != nil {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	// The code's id doubles as the session id, so the refresh tokens that
+	// come from it can be found if it is replayed.
+	refreshToken, err := makeRefreshToken(cfg, r, uuid.New(), refreshSession{
+		userID:     dbUser.ID,
+		familyID:   dbCode.ID,
+		deviceName: dbClient.Name,
+		clientID:   uuid.NullUUID{UUID: dbClient.ID, Valid: true},
+		scope:      sql.NullString{String: dbCode.Scope, Valid: true},
+	})
+	if err != nil {
+		respondWithOAuthError(w, 500, oauthError{"server_error", "something went wrong"})
+		return
+	}
+
+	cfg.respondWithOAuthToken(w, dbUser, dbClient, dbCode.ID, strings.Fields(dbCode.Scope), refreshToken)
+}
+
+// grantRefreshToken rotates a refresh token issued to the client. The
+// client may ask for fewer scopes than were granted, but never more.
+func (cfg *apiConfig) grantRefreshToken(w http.ResponseWriter, r *http.Request, dbClient database.OauthClient) {
+	invalidGrant := oauthError{"invalid_grant", "refresh token is invalid or expired"}
+
+	dbRefreshToken, err := cfg.findRefreshToken(r.Context(), r.PostForm.Get("refresh_token"))
+	if err != nil || !dbRefreshToken.ClientID.Valid || dbRefreshToken.ClientID.UUID != dbClient.ID {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	scopes := strings.Fields(dbRefreshToken.Scope.String)
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(scopes, scope) {
+				respondWithOAuthError(w, 400, oauthError{"invalid_scope", fmt.Sprintf("scope %q was not granted", scope)})
+				return
+			}
+		}
+		scopes = requested
+	}
+
+	refreshToken, resErr := cfg.rotateRefreshToken(r, dbRefreshToken)
+	if resErr.err != nil {
+		if resErr.code == 500 {
+			respondWithOAuthError(w, 500, oauthError{"server_error", "something went wrong"})
+			return
+		}
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), dbRefreshToken.UserID)
+	if err != nil {
+		respondWithOAuthError(w, 400, invalidGrant)
+		return
+	}
+
+	cfg.respondWithOAuthToken(w, dbUser, dbClient, dbRefreshToken.FamilyID, scopes, refreshToken)
+}
+
+func (cfg *apiConfig) revokeReusedAuthorizationCode(ctx context.Context, dbCode database.OauthAuthorizationCode) {
+	log.Printf("SECURITY: authorization code reused for user %s by client %s, revoking its tokens", dbCode.UserID, dbCode.ClientID)
+
+	err := cfg.db.RevokeRefreshTokenFamily(ctx, dbCode.ID)
+	if err != nil {
+		log.Printf("Error revoking refresh token family %s: %s", dbCode.ID, err)
+	}
+
+	_, err = cfg.db.BumpTokenVersion(ctx, dbCode.UserID)
+	if err != nil {
+		log.Printf("Error revoking access tokens for user %s: %s", dbCode.UserID, err)
+	}
+}
+
+// respondWithOAuthToken issues an access token with the granted scopes. It
+// is an ordinary Chirpy access token, so the API treats it like any other,
+// except that it names the client it was issued to. sessionID is the
+// refresh token family, so revoking the client's session revokes it too.
+func (cfg *apiConfig) respondWithOAuthToken(w http.ResponseWriter, dbUser database.User, dbClient database.OauthClient, sessionID uuid.UUID, scopes []string, refreshToken string) {
+	token, err := auth.MakeJWT(dbUser.ID, cfg.tokens, oauthAccessTokenDuration, scopes, dbUser.TokenVersion,
+		uuid.NullUUID{UUID: dbClient.ID, Valid: true}, uuid.NullUUID{UUID: sessionID, Valid: true})
+	if err != nil {
+		respondWithOAuthError(w, 500, oauthError{"server_error", "something went wrong"})
+		return
+	}
+
+	respondWithJSON(w, 200, OAuthToken{
+		AccessToken:  token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthErr oauthError) error {
+	return respondWithJSON(w, code, map[string]string{
+		"error":             oauthErr.code,
+		"error_description": oauthErr.description,
+	})
+}
+
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
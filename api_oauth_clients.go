@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handleCreateOAuthClient registers a third-party app. Confidential
+// clients get a secret, which is only ever shown in this response; public
+// clients, like mobile and single-page apps, get none and rely on PKCE.
+func (cfg *apiConfig) handleCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type requestCreateClient struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Public       bool     `json:"public"`
+	}
+
+	defer r.Body.Close()
+	var reqClient requestCreateClient
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqClient)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	reqClient.Name = strings.TrimSpace(reqClient.Name)
+	if reqClient.Name == "" {
+		respondWithError(w, 400, "name required")
+		return
+	}
+	if len(reqClient.RedirectURIs) == 0 {
+		respondWithError(w, 400, "at least one redirect_uri required")
+		return
+	}
+	for _, redirectURI := range reqClient.RedirectURIs {
+		err = validateRedirectURI(redirectURI)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+	}
+	if len(reqClient.Scopes) == 0 {
+		respondWithError(w, 400, "at least one scope required")
+		return
+	}
+	for _, scope := range reqClient.Scopes {
+		if !slices.Contains(auth.UserScopes, scope) {
+			respondWithError(w, 400, fmt.Sprintf("unknown scope %q", scope))
+			return
+		}
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if !reqClient.Public {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashRefreshToken(secret), Valid: true}
+	}
+
+	dbClient, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.New(),
+		Name:         reqClient.Name,
+		SecretHash:   secretHash,
+		RedirectUris: reqClient.RedirectURIs,
+		Scopes:       slices.Compact(slices.Sorted(slices.Values(reqClient.Scopes))),
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	client := dbOAuthClientToOAuthClient(dbClient)
+	client.ClientSecret = secret
+	respondWithJSON(w, 201, client)
+}
+
+func (cfg *apiConfig) handleGetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	dbClients, err := cfg.db.ListOAuthClients(r.Context())
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	clients := []OAuthClient{}
+	for _, dbClient := range dbClients {
+		clients = append(clients, dbOAuthClientToOAuthClient(dbClient))
+	}
+
+	respondWithJSON(w, 200, clients)
+}
+
+// handleDeleteOAuthClient removes a client along with its authorization
+// codes and refresh tokens. Access tokens it already holds run out on
+// their own.
+func (cfg *apiConfig) handleDeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid client id")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteOAuthClient(r.Context(), clientID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "client not found")
+		return
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+// validateRedirectURI accepts absolute URIs without a fragment, using the
+// schemes RFC 8252 allows: https, plain http back to the loopback
+// interface for native apps, and private-use schemes named after a domain
+// in reverse, like com.example.app. Anything else, javascript: and data:
+// included, is refused so the authorization code can't end up run as
+// script.
+func validateRedirectURI(redirectURI string) error {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || !parsed.IsAbs() {
+		return fmt.Errorf("redirect_uri %q must be an absolute URI", redirectURI)
+	}
+	if parsed.Fragment != "" {
+		return fmt.Errorf("redirect_uri %q must not have a fragment", redirectURI)
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		if parsed.Host == "" {
+			return fmt.Errorf("redirect_uri %q must have a host", redirectURI)
+		}
+	case "http":
+		ip := net.ParseIP(parsed.Hostname())
+		if parsed.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("redirect_uri %q must use https", redirectURI)
+		}
+	default:
+		if !strings.Contains(parsed.Scheme, ".") {
+			return fmt.Errorf("redirect_uri %q must use https or a reverse domain name scheme", redirectURI)
+		}
+	}
+	return nil
+}
+
+type OAuthClient struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	Public       bool      `json:"public"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Created_at   time.Time `json:"created_at"`
+	Updated_at   time.Time `json:"updated_at"`
+}
+
+func dbOAuthClientToOAuthClient(dbClient database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ClientID:     dbClient.ID,
+		Name:         dbClient.Name,
+		Public:       !dbClient.SecretHash.Valid,
+		RedirectURIs: dbClient.RedirectUris,
+		Scopes:       dbClient.Scopes,
+		Created_at:   dbClient.CreatedAt,
+		Updated_at:   dbClient.UpdatedAt,
+	}
+}
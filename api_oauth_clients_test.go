@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestValidateRedirectURI(t *testing.T) {
+	tests := []struct {
+		redirectURI string
+		wantErr     bool
+	}{
+		{redirectURI: "https://example.com/callback"},
+		{redirectURI: "https://example.com:8443/callback?state=kept"},
+		{redirectURI: "http://localhost/callback"},
+		{redirectURI: "http://127.0.0.1:5000/callback"},
+		{redirectURI: "http://[::1]:5000/callback"},
+		{redirectURI: "com.example.app:/callback"},
+		{redirectURI: "com.example.app://callback"},
+		{redirectURI: "https://example.com/callback#fragment", wantErr: true},
+		{redirectURI: "/callback", wantErr: true},
+		{redirectURI: "https:///callback", wantErr: true},
+		{redirectURI: "http://example.com/callback", wantErr: true},
+		{redirectURI: "http://localhost.example.com/callback", wantErr: true},
+		{redirectURI: "javascript:alert(document.cookie)", wantErr: true},
+		{redirectURI: "JavaScript:alert(1)", wantErr: true},
+		{redirectURI: "data:text/html,<script>alert(1)</script>", wantErr: true},
+		{redirectURI: "vbscript:msgbox(1)", wantErr: true},
+		{redirectURI: "myapp://callback", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.redirectURI, func(t *testing.T) {
+			err := validateRedirectURI(tt.redirectURI)
+			if tt.wantErr && err == nil {
+				t.Errorf("validateRedirectURI(%q) accepted it", tt.redirectURI)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("validateRedirectURI(%q) returned error: %v", tt.redirectURI, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/KidMuon/chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// handleForgotPassword emails a password reset link. It responds the same
+// way whether or not the email belongs to an account, and is throttled
+// per email and per IP.
+func (cfg *apiConfig) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type requestForgot struct {
+		Email string `json:"email"`
+	}
+
+	defer r.Body.Close()
+	var reqForgot requestForgot
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqForgot)
+	if err != nil || reqForgot.Email == "" {
+		respondWithError(w, 400, "email required")
+		return
+	}
+
+	if !cfg.checkEmailLinkThrottle(w, r, reqForgot.Email) {
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByEmail(r.Context(), reqForgot.Email)
+	if err != nil {
+		respondWithJSON(w, 204, nil)
+		return
+	}
+
+	token, err := cfg.issueUserToken(r.Context(), dbUser, userTokenPasswordReset, passwordResetTokenDuration)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	cfg.sendMail(r.Context(), mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"To choose a new password, open this link within the next hour:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n",
+			cfg.appURL("/app/reset-password", token)),
+	})
+
+	respondWithJSON(w, 204, nil)
+}
+
+// handleResetPassword sets a new password from an emailed reset token and
+// signs the account out everywhere.
+func (cfg *apiConfig) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	type requestReset struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	defer r.Body.Close()
+	var reqReset requestReset
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqReset)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	if reqReset.Password == "" {
+		respondWithError(w, 400, "password required")
+		return
+	}
+
+	// The token is only used up once the new password is accepted, so a
+	// password that fails the policy doesn't cost the user their link.
+	dbUserToken, err := cfg.checkUserToken(r.Context(), reqReset.Token, userTokenPasswordReset)
+	if err != nil {
+		respondWithError(w, 400, "invalid or expired token")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), dbUserToken.UserID)
+	if err != nil || dbUser.Email != dbUserToken.Email {
+		respondWithError(w, 400, "invalid or expired token")
+		return
+	}
+
+	resErr := cfg.checkPasswordPolicy(reqReset.Password, dbUser.Email)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	hashedPassword, err := cfg.hashPassword(reqReset.Password)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	err = cfg.useUserToken(r.Context(), dbUserToken)
+	if err != nil {
+		respondWithError(w, 400, "invalid or expired token")
+		return
+	}
+
+	_, err = cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+		ID:             dbUser.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	// A reset may follow a takeover, so nothing the attacker could have
+	// signed in with survives it.
+	_, err = cfg.revokeCredentials(r.Context(), dbUser.ID, uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	// Following the link proved the user can read mail sent to the address.
+	if !dbUser.EmailVerifiedAt.Valid {
+		_, err = cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+			ID:    dbUser.ID,
+			Email: dbUser.Email,
+		})
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+	}
+
+	cfg.recordLoginSuccess(r, dbUser.Email)
+
+	respondWithJSON(w, 204, nil)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxLocationLength    = 30
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+func (cfg *apiConfig) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	handleOrID := r.PathValue("handleOrID")
+
+	var dbUser database.User
+	var err error
+	if userID, parseErr := uuid.Parse(handleOrID); parseErr == nil {
+		dbUser, err = cfg.db.GetUserByID(r.Context(), userID)
+	} else {
+		dbUser, err = cfg.db.GetUserByHandle(r.Context(), strings.TrimPrefix(handleOrID, "@"))
+	}
+	if err != nil || dbUser.DeletionRequestedAt.Valid || dbUser.DeletedAt.Valid {
+		respondWithError(w, 404, "user not found")
+		return
+	}
+
+	respondWithJSON(w, 200, dbUserToPublicProfile(dbUser))
+}
+
+// PublicProfile is the view of a user anyone may see. It must never carry
+// the email address or password hash.
+type PublicProfile struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	Location    string    `json:"location"`
+	Created_at  time.Time `json:"created_at"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func dbUserToPublicProfile(dbUser database.User) PublicProfile {
+	return PublicProfile{
+		ID:          dbUser.ID,
+		Handle:      dbUser.Handle.String,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		Location:    dbUser.Location,
+		Created_at:  dbUser.CreatedAt,
+		IsChirpyRed: dbUser.IsChirpyRed.Bool,
+	}
+}
+
+// normalizeHandle strips an optional leading @ and checks what is left.
+func normalizeHandle(handle string) (string, error) {
+	handle = strings.TrimPrefix(strings.TrimSpace(handle), "@")
+	if !handlePattern.MatchString(handle) {
+		return "", fmt.Errorf("handle must be 3-30 letters, numbers or underscores")
+	}
+	return handle, nil
+}
+
+func validateProfileField(name, value string, maxLength int) error {
+	if utf8.RuneCountInString(value) > maxLength {
+		return fmt.Errorf("%s must be at most %d characters", name, maxLength)
+	}
+	return nil
+}
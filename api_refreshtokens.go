@@ -2,35 +2,95 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/KidMuon/chirpy/internal/auth"
 	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
 )
 
-func makeRefreshToken(cfg *apiConfig, user User) (string, error) {
-	refreshToken, err := auth.MakeRefreshToken()
+// refreshSession is what a refresh token passes on to the token that
+// replaces it. Tokens issued to an OAuth client also carry the client and
+// the scopes the user granted it.
+type refreshSession struct {
+	userID     uuid.UUID
+	familyID   uuid.UUID
+	deviceName string
+	clientID   uuid.NullUUID
+	scope      sql.NullString
+}
+
+func refreshSessionOf(dbRefreshToken database.RefreshToken) refreshSession {
+	return refreshSession{
+		userID:     dbRefreshToken.UserID,
+		familyID:   dbRefreshToken.FamilyID,
+		deviceName: dbRefreshToken.DeviceName,
+		clientID:   dbRefreshToken.ClientID,
+		scope:      dbRefreshToken.Scope,
+	}
+}
+
+// makeRefreshToken issues a refresh token in the given family. Logging in
+// starts a new family; every refresh after that stays in it. Only a hash of
+// the token's secret is stored, along with the client that asked for it.
+func makeRefreshToken(cfg *apiConfig, r *http.Request, tokenID uuid.UUID, session refreshSession) (string, error) {
+	secret, err := auth.MakeRefreshToken()
 	if err != nil {
 		return "", err
 	}
 
 	refreshTokenToCreate := database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID:    user.Id,
-		ExpiresAt: time.Now().AddDate(0, 0, 60),
+		ID:         tokenID,
+		TokenHash:  auth.HashRefreshToken(secret),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     session.userID,
+		ExpiresAt:  time.Now().AddDate(0, 0, 60),
+		FamilyID:   session.familyID,
+		UserAgent:  r.UserAgent(),
+		IpAddress:  clientIP(r),
+		DeviceName: session.deviceName,
+		LastUsedAt: time.Now(),
+		ClientID:   session.clientID,
+		Scope:      session.scope,
 	}
 
-	_, err = cfg.db.CreateRefreshToken(context.Background(), refreshTokenToCreate)
+	_, err = cfg.db.CreateRefreshToken(r.Context(), refreshTokenToCreate)
 	if err != nil {
 		return "", err
 	}
 
-	return refreshToken, nil
+	return auth.FormatRefreshToken(tokenID, secret), nil
 }
 
+// findRefreshToken looks a refresh token up by its id and checks its secret
+// against the stored hash. Revoked and expired tokens are still returned.
+func (cfg *apiConfig) findRefreshToken(ctx context.Context, refreshToken string) (database.RefreshToken, error) {
+	tokenID, secret, err := auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	dbRefreshToken, err := cfg.db.FindRefreshToken(ctx, tokenID)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	err = auth.CheckRefreshTokenHash(secret, dbRefreshToken.TokenHash)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	return dbRefreshToken, nil
+}
+
+// handleRefresh trades a refresh token for a new access token and a new
+// refresh token. Tokens issued to OAuth clients are refreshed through
+// /oauth/token instead, so they can't be swapped for full access here.
 func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -38,22 +98,90 @@ func (cfg *apiConfig) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbRefreshToken, err := cfg.db.FindRefreshToken(context.Background(), refreshToken)
-	if err != nil || dbRefreshToken.Token == "" {
+	dbRefreshToken, err := cfg.findRefreshToken(r.Context(), refreshToken)
+	if err != nil || dbRefreshToken.ClientID.Valid {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	newRefreshToken, resErr := cfg.rotateRefreshToken(r, dbRefreshToken)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), dbRefreshToken.UserID)
+	if err != nil {
 		respondWithError(w, 401, "unauthorized")
 		return
 	}
 
 	// make a new access pin for the user
-	token, err := auth.MakeJWT(dbRefreshToken.UserID, cfg.tokenSecret, time.Duration(3600*1e9))
+	token, err := cfg.makeAccessToken(dbUser, uuid.NullUUID{UUID: dbRefreshToken.FamilyID, Valid: true}, time.Duration(3600*1e9))
 	if err != nil {
 		respondWithError(w, 500, "something went wrong")
 		return
 	}
 
-	respondWithJSON(w, 200, AccessTokenFromRefresh{Token: token})
+	respondWithJSON(w, 200, AccessTokenFromRefresh{Token: token, RefreshToken: newRefreshToken})
+}
+
+// rotateRefreshToken uses up a refresh token and issues the next one in
+// its family. Each refresh token can only be used once; presenting one
+// that has already been revoked means it has leaked, so the whole family
+// is revoked and the user has to log in again.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, dbRefreshToken database.RefreshToken) (string, responseError) {
+	if dbRefreshToken.RevokedAt.Valid {
+		cfg.revokeReusedRefreshToken(r.Context(), dbRefreshToken)
+		return "", responseError{code: 401, err: fmt.Errorf("unauthorized")}
+	}
+
+	if dbRefreshToken.ExpiresAt.Before(time.Now()) {
+		return "", responseError{code: 401, err: fmt.Errorf("unauthorized")}
+	}
+
+	newTokenID := uuid.New()
+
+	// Revoking the old token only succeeds once, so two requests racing
+	// with the same token cannot both get a new one.
+	_, err := cfg.db.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+		ReplacedBy: newTokenID,
+		ID:         dbRefreshToken.ID,
+	})
+	if err == sql.ErrNoRows {
+		cfg.revokeReusedRefreshToken(r.Context(), dbRefreshToken)
+		return "", responseError{code: 401, err: fmt.Errorf("unauthorized")}
+	}
+	if err != nil {
+		return "", responseError{code: 500, err: fmt.Errorf("something went wrong")}
+	}
+
+	newRefreshToken, err := makeRefreshToken(cfg, r, newTokenID, refreshSessionOf(dbRefreshToken))
+	if err != nil {
+		return "", responseError{code: 500, err: fmt.Errorf("something went wrong")}
+	}
+
+	return newRefreshToken, responseError{}
 }
 
+func (cfg *apiConfig) revokeReusedRefreshToken(ctx context.Context, dbRefreshToken database.RefreshToken) {
+	log.Printf("SECURITY: revoked refresh token reused for user %s, revoking token family %s", dbRefreshToken.UserID, dbRefreshToken.FamilyID)
+
+	err := cfg.db.RevokeRefreshTokenFamily(ctx, dbRefreshToken.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking refresh token family %s: %s", dbRefreshToken.FamilyID, err)
+	}
+
+	_, err = cfg.db.BumpTokenVersion(ctx, dbRefreshToken.UserID)
+	if err != nil {
+		log.Printf("Error revoking access tokens for user %s: %s", dbRefreshToken.UserID, err)
+	}
+}
+
+// handleRevoke logs out of the session the refresh token belongs to,
+// ending its access tokens along with every refresh token in the family.
+// A token that was already rotated has leaked, so it is handled like
+// reuse at /api/refresh.
 func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -61,7 +189,19 @@ func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.db.RevokeRefreshToken(context.Background(), refreshToken)
+	dbRefreshToken, err := cfg.findRefreshToken(r.Context(), refreshToken)
+	if err != nil {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	if dbRefreshToken.ReplacedBy.Valid {
+		cfg.revokeReusedRefreshToken(r.Context(), dbRefreshToken)
+		respondWithJSON(w, 204, nil)
+		return
+	}
+
+	err = cfg.db.RevokeRefreshTokenFamily(r.Context(), dbRefreshToken.FamilyID)
 	if err != nil {
 		respondWithError(w, 500, "something went wrong")
 		return
@@ -71,5 +211,6 @@ func (cfg *apiConfig) handleRevoke(w http.ResponseWriter, r *http.Request) {
 }
 
 type AccessTokenFromRefresh struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleSearchChirps(w http.ResponseWriter, r *http.Request) {
+	tsQuery, err := buildTSQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	pageReq, resErr := getPageRequest(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	authorIDs, err := parseUUIDList(r.URL.Query()["author_id"])
+	if err != nil {
+		respondWithError(w, 400, "invalid author_id")
+		return
+	}
+	excludeAuthorIDs, err := parseUUIDList(r.URL.Query()["exclude_author"])
+	if err != nil {
+		respondWithError(w, 400, "invalid exclude_author")
+		return
+	}
+
+	searchParams := database.SearchChirpsParams{
+		Query:            tsQuery,
+		AuthorIds:        authorIDs,
+		ExcludeAuthorIds: excludeAuthorIDs,
+		PageLimit:        pageReq.fetchLimit(),
+	}
+	if pageReq.cursor != nil {
+		searchParams.CursorRank = sql.NullFloat64{Float64: float64(pageReq.cursor.Rank), Valid: true}
+		searchParams.CursorCreatedAt = sql.NullTime{Time: pageReq.cursor.CreatedAt, Valid: true}
+		searchParams.CursorID = uuid.NullUUID{UUID: pageReq.cursor.ID, Valid: true}
+	}
+
+	var rows []database.SearchChirpsRow
+	if pageReq.backward() {
+		var reverseRows []database.SearchChirpsReverseRow
+		reverseRows, err = cfg.db.SearchChirpsReverse(r.Context(), database.SearchChirpsReverseParams(searchParams))
+		for _, row := range reverseRows {
+			rows = append(rows, database.SearchChirpsRow(row))
+		}
+	} else {
+		rows, err = cfg.db.SearchChirps(r.Context(), searchParams)
+	}
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	searchPage := paginate(rows, pageReq, func(row database.SearchChirpsRow) pageCursor {
+		cursor := dbChirpCursor(row.Chirp)
+		cursor.Rank = row.Rank
+		return cursor
+	})
+
+	dbChirps := []database.Chirp{}
+	for _, row := range searchPage.items {
+		dbChirps = append(dbChirps, row.Chirp)
+	}
+
+	chirps, err := cfg.dbChirpsToChirps(r.Context(), dbChirps, viewerIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	setLinkHeader(w, r, searchPage.nextCursor, searchPage.prevCursor)
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: searchPage.nextCursor,
+		PrevCursor: searchPage.prevCursor,
+	})
+}
+
+// buildTSQuery turns a search box string into a to_tsquery expression.
+// Terms are ANDed together, "quoted text" becomes a phrase, a trailing *
+// makes a prefix match and a leading - excludes the term.
+func buildTSQuery(search string) (string, error) {
+	var terms []string
+	var positive bool
+
+	for len(search) > 0 {
+		search = strings.TrimLeftFunc(search, unicode.IsSpace)
+		if search == "" {
+			break
+		}
+
+		negate := false
+		if search[0] == '-' {
+			negate = true
+			search = search[1:]
+		}
+
+		var raw string
+		phrase := false
+		if strings.HasPrefix(search, "\"") {
+			end := strings.Index(search[1:], "\"")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated phrase in search query")
+			}
+			raw = search[1 : end+1]
+			search = search[end+2:]
+			phrase = true
+		} else {
+			end := strings.IndexFunc(search, unicode.IsSpace)
+			if end < 0 {
+				end = len(search)
+			}
+			raw = search[:end]
+			search = search[end:]
+		}
+
+		prefix := !phrase && strings.HasSuffix(raw, "*")
+		words := strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if negate {
+			term = "!" + term
+		} else {
+			positive = true
+		}
+		terms = append(terms, term)
+	}
+
+	if !positive {
+		return "", fmt.Errorf("search query must contain at least one term")
+	}
+	return strings.Join(terms, " & "), nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  string
+		want    string
+		wantErr bool
+	}{
+		{name: "one word", search: "hello", want: "hello"},
+		{name: "words are ANDed", search: "hello  world", want: "hello & world"},
+		{name: "phrase", search: `"hello world"`, want: "(hello <-> world)"},
+		{name: "prefix", search: "chirp*", want: "chirp:*"},
+		{name: "prefix on the last word of a compound", search: "multi-word*", want: "(multi <-> word:*)"},
+		{name: "no prefix inside a phrase", search: `"chirp*"`, want: "chirp"},
+		{name: "exclusion", search: "hello -spam", want: "hello & !spam"},
+		{name: "excluded phrase", search: `-"buy now" deals`, want: "!(buy <-> now) & deals"},
+		{name: "unicode", search: "café 日本", want: "café & 日本"},
+		{name: "tsquery operators are dropped", search: "a&b|c", want: "(a <-> b <-> c)"},
+		{name: "tsquery syntax is dropped", search: "foo:*bar !(x)", want: "(foo <-> bar) & x"},
+		{name: "punctuation only terms are skipped", search: "!!! hello ...", want: "hello"},
+		{name: "lone dash", search: "- hello", want: "hello"},
+		{name: "empty", search: "", wantErr: true},
+		{name: "spaces", search: "   ", wantErr: true},
+		{name: "punctuation only", search: "&|!", wantErr: true},
+		{name: "only exclusions", search: "-spam -ads", wantErr: true},
+		{name: "unterminated phrase", search: `"hello world`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildTSQuery(tt.search)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("buildTSQuery(%q) = %q, want an error", tt.search, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildTSQuery(%q) returned error: %v", tt.search, err)
+			}
+			if got != tt.want {
+				t.Errorf("buildTSQuery(%q) = %q, want %q", tt.search, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxDeviceNameLength = 100
+
+// A session is one refresh token family: it starts at login and survives
+// every refresh until it is revoked or expires. Its id is the family id, so
+// sessions can be listed and revoked without exposing the tokens.
+func (cfg *apiConfig) handleGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	dbSessions, err := cfg.db.ListSessions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	sessions := []Session{}
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, dbSessionToSession(dbSession))
+	}
+
+	respondWithJSON(w, 200, sessions)
+}
+
+// handleDeleteSession signs one device out. Access tokens name the session
+// they were issued in, so only that session's tokens stop working; the
+// user's other sessions, including the caller's, are left alone.
+func (cfg *apiConfig) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, 400, "invalid session id")
+		return
+	}
+
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "session not found")
+		return
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+// handleRevokeOtherSessions logs out everywhere except the session the
+// request comes from. Like /api/revoke it takes the refresh token, since
+// that is what identifies the current session. Access tokens issued in the
+// other sessions are revoked with them.
+func (cfg *apiConfig) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 400, "no refresh token present")
+		return
+	}
+
+	// OAuth clients only get to manage their own token, not the user's
+	// other sessions.
+	dbRefreshToken, err := cfg.findRefreshToken(r.Context(), refreshToken)
+	if err != nil || dbRefreshToken.ClientID.Valid || dbRefreshToken.RevokedAt.Valid || dbRefreshToken.ExpiresAt.Before(time.Now()) {
+		respondWithError(w, 401, "unauthorized")
+		return
+	}
+
+	_, err = cfg.db.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   dbRefreshToken.UserID,
+		FamilyID: dbRefreshToken.FamilyID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+// clientIP is the address the request came from. Forwarding headers are
+// ignored since anyone can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type Session struct {
+	ID           uuid.UUID  `json:"id"`
+	DeviceName   string     `json:"device_name"`
+	ClientID     *uuid.UUID `json:"client_id,omitempty"`
+	UserAgent    string     `json:"user_agent"`
+	IPAddress    string     `json:"ip_address"`
+	Signed_in_at time.Time  `json:"signed_in_at"`
+	Last_used_at time.Time  `json:"last_used_at"`
+	Expires_at   time.Time  `json:"expires_at"`
+}
+
+func dbSessionToSession(dbSession database.ListSessionsRow) Session {
+	session := Session{
+		ID:           dbSession.FamilyID,
+		DeviceName:   dbSession.DeviceName,
+		UserAgent:    dbSession.UserAgent,
+		IPAddress:    dbSession.IpAddress,
+		Signed_in_at: dbSession.SignedInAt,
+		Last_used_at: dbSession.LastUsedAt,
+		Expires_at:   dbSession.ExpiresAt,
+	}
+	if dbSession.ClientID.Valid {
+		clientID := dbSession.ClientID.UUID
+		session.ClientID = &clientID
+	}
+	return session
+}
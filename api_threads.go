@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleGetChirpReplies(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, resErr := getChirpIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	_, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, 404, "not found")
+		return
+	}
+
+	dbReplies, err := cfg.db.GetChirpReplies(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	replies, err := cfg.dbChirpsToChirps(r.Context(), dbReplies, viewerIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, replies)
+}
+
+func (cfg *apiConfig) handleGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, resErr := getChirpIDFromPath(r)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, 404, "not found")
+		return
+	}
+
+	dbThread, err := cfg.db.GetChirpThread(r.Context(), chirpThreadID(dbChirp))
+	if err != nil || len(dbThread) == 0 {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	thread, err := cfg.dbChirpsToChirps(r.Context(), dbThread, viewerIDFromContext(r.Context()))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	root := buildChirpThread(thread)
+	if root == nil {
+		respondWithError(w, 404, "not found")
+		return
+	}
+
+	respondWithJSON(w, 200, root)
+}
+
+type ChirpThreadNode struct {
+	Chirp
+	Replies []*ChirpThreadNode `json:"replies"`
+}
+
+// buildChirpThread nests a thread returned in creation order under its
+// root. Replies whose parent has been removed entirely hang off the root.
+// If the root itself is gone, the oldest chirp left stands in for it. It
+// returns nil only for an empty thread.
+func buildChirpThread(thread []Chirp) *ChirpThreadNode {
+	nodes := make(map[uuid.UUID]*ChirpThreadNode, len(thread))
+	var root *ChirpThreadNode
+
+	for _, chirp := range thread {
+		node := &ChirpThreadNode{Chirp: chirp, Replies: []*ChirpThreadNode{}}
+		nodes[chirp.ID] = node
+		if chirp.ThreadID == chirp.ID {
+			root = node
+		}
+	}
+	if root == nil {
+		if len(thread) == 0 {
+			return nil
+		}
+		root = nodes[thread[0].ID]
+	}
+
+	for _, chirp := range thread {
+		if nodes[chirp.ID] == root {
+			continue
+		}
+		parent := root
+		if chirp.InReplyTo != nil {
+			if parentNode, ok := nodes[*chirp.InReplyTo]; ok {
+				parent = parentNode
+			}
+		}
+		parent.Replies = append(parent.Replies, nodes[chirp.ID])
+	}
+
+	return root
+}
+
+func getChirpIDFromPath(r *http.Request) (uuid.UUID, responseError) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		return uuid.UUID{}, responseError{code: 400, err: fmt.Errorf("invalid chirp id")}
+	}
+	return chirpUUID, responseError{}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBuildChirpThread(t *testing.T) {
+	rootID, a, b, c, gone := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	root := Chirp{ID: rootID, ThreadID: rootID}
+	reply := func(id uuid.UUID, inReplyTo uuid.UUID) Chirp {
+		return Chirp{ID: id, ThreadID: rootID, InReplyTo: &inReplyTo}
+	}
+
+	tests := []struct {
+		name     string
+		thread   []Chirp
+		wantRoot uuid.UUID
+		// want maps each chirp in the tree to its replies, in order.
+		want map[uuid.UUID][]uuid.UUID
+	}{
+		{
+			name:     "root only",
+			thread:   []Chirp{root},
+			wantRoot: rootID,
+			want:     map[uuid.UUID][]uuid.UUID{rootID: {}},
+		},
+		{
+			name:     "nested replies",
+			thread:   []Chirp{root, reply(a, rootID), reply(b, a), reply(c, rootID)},
+			wantRoot: rootID,
+			want:     map[uuid.UUID][]uuid.UUID{rootID: {a, c}, a: {b}, b: {}, c: {}},
+		},
+		{
+			name:     "reply to a removed chirp hangs off the root",
+			thread:   []Chirp{root, reply(a, rootID), reply(b, gone)},
+			wantRoot: rootID,
+			want:     map[uuid.UUID][]uuid.UUID{rootID: {a, b}, a: {}, b: {}},
+		},
+		{
+			name:     "missing root falls back to the oldest chirp",
+			thread:   []Chirp{reply(a, rootID), reply(b, a), reply(c, rootID)},
+			wantRoot: a,
+			want:     map[uuid.UUID][]uuid.UUID{a: {b, c}, b: {}, c: {}},
+		},
+		{
+			name:     "missing root and parent",
+			thread:   []Chirp{reply(a, gone), reply(b, gone)},
+			wantRoot: a,
+			want:     map[uuid.UUID][]uuid.UUID{a: {b}, b: {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildChirpThread(tt.thread)
+			if got == nil {
+				t.Fatal("buildChirpThread returned nil")
+			}
+			if got.ID != tt.wantRoot {
+				t.Errorf("root = %s, want %s", got.ID, tt.wantRoot)
+			}
+
+			seen := map[uuid.UUID][]uuid.UUID{}
+			var walk func(node *ChirpThreadNode)
+			walk = func(node *ChirpThreadNode) {
+				if _, ok := seen[node.ID]; ok {
+					t.Fatalf("chirp %s appears twice", node.ID)
+				}
+				seen[node.ID] = []uuid.UUID{}
+				for _, child := range node.Replies {
+					seen[node.ID] = append(seen[node.ID], child.ID)
+					walk(child)
+				}
+			}
+			walk(got)
+
+			if len(seen) != len(tt.want) {
+				t.Errorf("tree has %d chirps, want %d", len(seen), len(tt.want))
+			}
+			for id, wantReplies := range tt.want {
+				if !slices.Equal(seen[id], wantReplies) {
+					t.Errorf("replies of %s = %v, want %v", id, seen[id], wantReplies)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildChirpThreadEmpty(t *testing.T) {
+	if got := buildChirpThread(nil); got != nil {
+		t.Errorf("buildChirpThread(nil) = %+v, want nil", got)
+	}
+}
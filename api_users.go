@@ -1,10 +1,11 @@
 package main
 
 import (
-	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/KidMuon/chirpy/internal/auth"
@@ -19,25 +20,63 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resErr = cfg.checkPasswordPolicy(reqUser.Password, reqUser.Email)
+	if resErr.err != nil {
+		respondWithError(w, resErr.code, resErr.Error())
+		return
+	}
+
+	deviceName := strings.TrimSpace(reqUser.DeviceName)
+	if len(deviceName) > maxDeviceNameLength {
+		respondWithError(w, 400, fmt.Sprintf("device_name must be at most %d characters", maxDeviceNameLength))
+		return
+	}
+
+	hashedPassword, err := cfg.hashPassword(reqUser.Password)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
 	userToCreate := database.CreateUserParams{
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 		Email:          reqUser.Email,
-		HashedPassword: reqUser.hashed_password,
+		HashedPassword: hashedPassword,
 	}
+	if reqUser.Handle != "" {
+		handle, err := normalizeHandle(reqUser.Handle)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		userToCreate.Handle = sql.NullString{String: handle, Valid: true}
+	}
+
 	dbUser, err := cfg.db.CreateUser(r.Context(), userToCreate)
+	if isUniqueViolationOf(err, "users_handle_lower_idx") {
+		respondWithError(w, 409, "handle already taken")
+		return
+	}
 	if err != nil {
 		respondWithError(w, 400, "email already in use")
 		return
 	}
 	user := dbUserToUser(dbUser)
 
-	token, err := auth.MakeJWT(user.Id, cfg.tokenSecret, reqUser.expiration_duration)
+	err = cfg.sendVerificationEmail(r.Context(), dbUser)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return
+	}
+
+	// Signing up logs the user in with a session of its own, so the token
+	// ends with the session like any other.
+	user.Token, user.RefreshToken, err = cfg.startSession(r, dbUser, deviceName, reqUser.expiration_duration)
 	if err != nil {
-		respondWithError(w, 500, err.Error())
+		respondWithError(w, 500, "something went wrong")
 		return
 	}
-	user.Token = token
 
 	respondWithJSON(w, 201, user)
 }
@@ -49,76 +88,240 @@ func (cfg *apiConfig) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !cfg.checkLoginThrottle(w, r, reqUser.Email) {
+		return
+	}
+
 	dbUser, err := cfg.db.GetUserByEmail(r.Context(), reqUser.Email)
 	if err != nil {
+		cfg.recordLoginFailure(r, reqUser.Email)
 		respondWithError(w, 401, "incorrect email or password")
 		return
 	}
 
-	err = auth.CheckPasswordHash(reqUser.Password, dbUser.HashedPassword)
+	err = cfg.verifyPassword(r.Context(), dbUser, reqUser.Password)
 	if err != nil {
+		cfg.recordLoginFailure(r, reqUser.Email)
 		respondWithError(w, 401, "incorrect email or password")
 		return
 	}
-	user := dbUserToUser(dbUser)
 
-	token, err := auth.MakeJWT(user.Id, cfg.tokenSecret, reqUser.expiration_duration)
-	if err != nil {
-		respondWithError(w, 500, "something went wrong")
+	if dbUser.TotpEnabledAt.Valid {
+		mfaToken, err := cfg.issueMFAChallenge(r.Context(), dbUser)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+		respondWithJSON(w, 200, MFAChallenge{MFARequired: true, MFAToken: mfaToken})
+		return
+	}
+
+	cfg.recordLoginSuccess(r, reqUser.Email)
+	cfg.completeLogin(w, r, dbUser, reqUser.DeviceName, reqUser.expiration_duration)
+}
+
+// completeLogin starts a session once the user has fully proven who they
+// are, responding with the user and their access and refresh tokens.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, dbUser database.User, deviceName string, expiresIn time.Duration) {
+	if dbUser.SuspendedAt.Valid {
+		respondWithError(w, 403, "account suspended")
+		return
+	}
+
+	deviceName = strings.TrimSpace(deviceName)
+	if len(deviceName) > maxDeviceNameLength {
+		respondWithError(w, 400, fmt.Sprintf("device_name must be at most %d characters", maxDeviceNameLength))
 		return
 	}
-	user.Token = token
 
-	refreshToken, err := makeRefreshToken(cfg, user)
+	if dbUser.DeletionRequestedAt.Valid {
+		err := cfg.db.CancelUserDeletion(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+	}
+	user := dbUserToUser(dbUser)
+
+	var err error
+	user.Token, user.RefreshToken, err = cfg.startSession(r, dbUser, deviceName, expiresIn)
 	if err != nil {
 		respondWithError(w, 500, "something went wrong")
 		return
 	}
-	user.RefreshToken = refreshToken
 
 	respondWithJSON(w, 200, user)
 }
 
+// startSession starts a new refresh token family and issues the first
+// refresh token in it, along with an access token tied to the session so
+// logging the session out revokes it.
+func (cfg *apiConfig) startSession(r *http.Request, dbUser database.User, deviceName string, expiresIn time.Duration) (string, string, error) {
+	sessionID := uuid.New()
+	token, err := cfg.makeAccessToken(dbUser, uuid.NullUUID{UUID: sessionID, Valid: true}, expiresIn)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := makeRefreshToken(cfg, r, uuid.New(), refreshSession{
+		userID:     dbUser.ID,
+		familyID:   sessionID,
+		deviceName: deviceName,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, refreshToken, nil
+}
+
+// handleUpdateUser applies a partial update: only the fields present in the
+// request change. Changing the email or password needs the current password.
 func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	reqUser, resErr := getUserFromRequest(r)
-	if resErr.err != nil {
-		respondWithError(w, resErr.code, resErr.Error())
-		return
+	type requestUserUpdate struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		Handle          *string `json:"handle"`
+		DisplayName     *string `json:"display_name"`
+		Bio             *string `json:"bio"`
+		Location        *string `json:"location"`
 	}
 
-	authToken, err := auth.GetBearerToken(r.Header)
+	userID := userIDFromContext(r.Context())
+
+	defer r.Body.Close()
+	var reqUpdate requestUserUpdate
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqUpdate)
 	if err != nil {
-		respondWithError(w, 401, "no authentication found")
+		respondWithError(w, 400, "malformed request")
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authToken, cfg.tokenSecret)
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, 401, err.Error())
+		respondWithError(w, 401, "unauthorized")
 		return
 	}
 
-	userEmail := reqUser.Email
+	userToUpdate := database.UpdateUserParams{ID: userID}
 
-	userHashedPassword, err := auth.HashPassword(reqUser.Password)
-	if err != nil {
-		respondWithError(w, 500, "something went wrong")
-		return
+	if reqUpdate.Email != nil || reqUpdate.Password != nil {
+		err = cfg.verifyPassword(r.Context(), dbUser, reqUpdate.CurrentPassword)
+		if err != nil {
+			respondWithError(w, 403, "current password is incorrect")
+			return
+		}
+	}
+
+	if reqUpdate.Email != nil {
+		email := strings.TrimSpace(*reqUpdate.Email)
+		if email == "" {
+			respondWithError(w, 400, "email cannot be empty")
+			return
+		}
+		userToUpdate.Email = sql.NullString{String: email, Valid: true}
 	}
 
-	userToUpdate := database.UpdateUserEmailAndPasswordParams{
-		ID:             userID,
-		Email:          userEmail,
-		HashedPassword: userHashedPassword,
+	if reqUpdate.Password != nil {
+		email := dbUser.Email
+		if userToUpdate.Email.Valid {
+			email = userToUpdate.Email.String
+		}
+		resErr := cfg.checkPasswordPolicy(*reqUpdate.Password, email)
+		if resErr.err != nil {
+			respondWithError(w, resErr.code, resErr.Error())
+			return
+		}
+		userHashedPassword, err := cfg.hashPassword(*reqUpdate.Password)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+		userToUpdate.HashedPassword = sql.NullString{String: userHashedPassword, Valid: true}
 	}
 
-	updatedDBUser, err := cfg.db.UpdateUserEmailAndPassword(context.Background(), userToUpdate)
+	if reqUpdate.Handle != nil {
+		handle, err := normalizeHandle(*reqUpdate.Handle)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		userToUpdate.Handle = sql.NullString{String: handle, Valid: true}
+	}
+
+	for _, field := range []struct {
+		name      string
+		value     *string
+		maxLength int
+		target    *sql.NullString
+	}{
+		{"display_name", reqUpdate.DisplayName, maxDisplayNameLength, &userToUpdate.DisplayName},
+		{"bio", reqUpdate.Bio, maxBioLength, &userToUpdate.Bio},
+		{"location", reqUpdate.Location, maxLocationLength, &userToUpdate.Location},
+	} {
+		if field.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*field.value)
+		err := validateProfileField(field.name, value, field.maxLength)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		*field.target = sql.NullString{String: value, Valid: true}
+	}
+
+	updatedDBUser, err := cfg.db.UpdateUser(r.Context(), userToUpdate)
+	if isUniqueViolationOf(err, "users_email_key") {
+		respondWithError(w, 409, "email already in use")
+		return
+	}
+	if isUniqueViolationOf(err, "users_handle_lower_idx") {
+		respondWithError(w, 409, "handle already taken")
+		return
+	}
 	if err != nil {
 		respondWithError(w, 500, "something went wrong")
 		return
 	}
 
-	respondWithJSON(w, 200, dbUserToUser(updatedDBUser))
+	user := dbUserToUser(updatedDBUser)
+
+	if updatedDBUser.Email != dbUser.Email {
+		err = cfg.sendVerificationEmail(r.Context(), updatedDBUser)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+	}
+
+	// A new password ends every other session and revokes API keys and
+	// every access token issued with the old one, including the one used
+	// for this request. The caller gets back a token allowing exactly what
+	// theirs did, so an OAuth client can't trade up for full access; a
+	// request made with an API key has no token to replace.
+	if reqUpdate.Password != nil {
+		accessToken, _ := accessTokenFromContext(r.Context())
+		updatedDBUser, err = cfg.revokeCredentials(r.Context(), userID, accessToken.SessionID)
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+		user = dbUserToUser(updatedDBUser)
+
+		if !accessToken.APIKeyID.Valid {
+			user.Token, err = auth.MakeJWT(updatedDBUser.ID, cfg.tokens, time.Duration(3600*1e9), accessToken.Scopes,
+				updatedDBUser.TokenVersion, accessToken.ClientID, accessToken.SessionID)
+			if err != nil {
+				respondWithError(w, 500, "something went wrong")
+				return
+			}
+		}
+	}
+
+	respondWithJSON(w, 200, user)
 }
 
 func getUserFromRequest(r *http.Request) (requestUser, responseError) {
@@ -135,12 +338,6 @@ func getUserFromRequest(r *http.Request) (requestUser, responseError) {
 		return requestUser{}, responseError{code: 400, err: fmt.Errorf("password required")}
 	}
 
-	hashed_password, err := auth.HashPassword(reqUser.Password)
-	if err != nil {
-		return requestUser{}, responseError{code: 500, err: fmt.Errorf("something went wrong")}
-	}
-	reqUser.hashed_password = hashed_password
-
 	reqUser.expiration_duration = time.Duration(3600 * 1e9)
 
 	return reqUser, responseError{}
@@ -149,26 +346,41 @@ func getUserFromRequest(r *http.Request) (requestUser, responseError) {
 type requestUser struct {
 	Email               string `json:"email"`
 	Password            string `json:"password"`
+	Handle              string `json:"handle"`
+	DeviceName          string `json:"device_name"`
 	expiration_duration time.Duration
-	hashed_password     string
 }
 
 type User struct {
-	Id           uuid.UUID `json:"id"`
-	Created_at   time.Time `json:"created_at"`
-	Updated_at   time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Id               uuid.UUID `json:"id"`
+	Created_at       time.Time `json:"created_at"`
+	Updated_at       time.Time `json:"updated_at"`
+	Email            string    `json:"email"`
+	Handle           string    `json:"handle,omitempty"`
+	DisplayName      string    `json:"display_name"`
+	Bio              string    `json:"bio"`
+	Location         string    `json:"location"`
+	Token            string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	IsChirpyRed      bool      `json:"is_chirpy_red"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	EmailVerified    bool      `json:"email_verified"`
+	Role             string    `json:"role"`
 }
 
 func dbUserToUser(dbUser database.User) User {
 	return User{
-		Id:          dbUser.ID,
-		Created_at:  dbUser.CreatedAt,
-		Updated_at:  dbUser.UpdatedAt,
-		Email:       dbUser.Email,
-		IsChirpyRed: dbUser.IsChirpyRed.Bool,
+		Id:               dbUser.ID,
+		Created_at:       dbUser.CreatedAt,
+		Updated_at:       dbUser.UpdatedAt,
+		Email:            dbUser.Email,
+		Handle:           dbUser.Handle.String,
+		DisplayName:      dbUser.DisplayName,
+		Bio:              dbUser.Bio,
+		Location:         dbUser.Location,
+		IsChirpyRed:      dbUser.IsChirpyRed.Bool,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+		EmailVerified:    dbUser.EmailVerifiedAt.Valid,
+		Role:             dbUser.Role,
 	}
 }
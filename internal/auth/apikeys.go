@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// apiKeyPrefix marks user API keys so they are easy to spot, and to scan
+// for, if one is pasted somewhere it shouldn't be.
+const apiKeyPrefix = "chirpy_"
+
+// FormatAPIKey builds a user API key from its id and secret, the same way
+// as a refresh token. Only the id and a hash of the secret are stored.
+func FormatAPIKey(id uuid.UUID, secret string) string {
+	return apiKeyPrefix + FormatRefreshToken(id, secret)
+}
+
+func ParseAPIKey(key string) (uuid.UUID, string, error) {
+	token, found := strings.CutPrefix(key, apiKeyPrefix)
+	if !found {
+		return uuid.Nil, "", fmt.Errorf("malformed api key")
+	}
+	id, secret, err := ParseRefreshToken(token)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("malformed api key")
+	}
+	return id, secret, nil
+}
+
+// GetAPIKey reads a user API key from an "Authorization: ApiKey" header.
+// Unlike GetPolkaAPIKey it fails for any other scheme, so callers can tell
+// an API key apart from a bearer token.
+func GetAPIKey(headers http.Header) (string, error) {
+	scheme, key, found := strings.Cut(strings.TrimSpace(headers.Get("Authorization")), " ")
+	if !found || !strings.EqualFold(scheme, "ApiKey") {
+		return "", fmt.Errorf("no api key present")
+	}
+	return strings.TrimSpace(key), nil
+}
@@ -11,15 +11,18 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// HashPassword hashes short, random secrets such as recovery codes. User
+// passwords go through the configured Hasher instead.
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 0)
-	if err != nil {
-		return "", fmt.Errorf("error hashing password")
-	}
-	return string(hash), nil
+	return BcryptHasher{Cost: bcrypt.DefaultCost}.Hash(password)
 }
 
+// CheckPasswordHash checks a password against a hash from any Hasher.
 func CheckPasswordHash(password, hash string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return checkArgon2id(password, hash)
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
 		return fmt.Errorf("incorrect password")
@@ -27,15 +30,34 @@ func CheckPasswordHash(password, hash string) error {
 	return nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	registeredClaims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
+func MakeJWT(userID uuid.UUID, tokenConfig TokenConfig, expiresIn time.Duration, scopes []string, version int32, clientID, sessionID uuid.NullUUID) (string, error) {
+	signingKey, err := tokenConfig.Keys.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenConfig.Issuer,
+			Audience:  jwt.ClaimStrings{tokenConfig.Audience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Scope:   strings.Join(scopes, " "),
+		Version: version,
+	}
+	if clientID.Valid {
+		claims.ClientID = clientID.UUID.String()
+	}
+	if sessionID.Valid {
+		claims.SessionID = sessionID.UUID.String()
+	}
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	if signingKey.ID != "" {
+		token.Header["kid"] = signingKey.ID
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, registeredClaims)
-	tokenString, err := token.SignedString([]byte(tokenSecret))
+	tokenString, err := token.SignedString(signingKey.signKey)
 
 	if err != nil {
 		return "", err
@@ -44,31 +66,54 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return tokenString, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-			return []byte{}, fmt.Errorf("incorrect signing method")
-		}
-		return []byte(tokenSecret), nil
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, keyFunc)
+// ValidateJWT checks the signature, expiry, issuer and audience of an
+// access token and returns who it was issued to and what it allows.
+func ValidateJWT(tokenString string, tokenConfig TokenConfig) (AccessToken, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&Claims{},
+		tokenConfig.Keys.keyFunc,
+		jwt.WithIssuer(tokenConfig.Issuer),
+		jwt.WithAudience(tokenConfig.Audience),
+		jwt.WithLeeway(tokenConfig.Leeway),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return AccessToken{}, err
 	}
 
-	uuid_string, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.UUID{}, err
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return AccessToken{}, fmt.Errorf("unexpected claims")
 	}
 
-	extracted_uuid, err := uuid.Parse(uuid_string)
+	extracted_uuid, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return AccessToken{}, err
+	}
+
+	accessToken := AccessToken{
+		UserID:  extracted_uuid,
+		Scopes:  strings.Fields(claims.Scope),
+		Version: claims.Version,
+	}
+	if claims.ClientID != "" {
+		clientID, err := uuid.Parse(claims.ClientID)
+		if err != nil {
+			return AccessToken{}, err
+		}
+		accessToken.ClientID = uuid.NullUUID{UUID: clientID, Valid: true}
+	}
+	if claims.SessionID != "" {
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return AccessToken{}, err
+		}
+		accessToken.SessionID = uuid.NullUUID{UUID: sessionID, Valid: true}
 	}
 
-	return extracted_uuid, nil
+	return accessToken, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
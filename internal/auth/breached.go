@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedPasswords is an offline copy of a breached password list such
+// as Have I Been Pwned's, in its SHA-1 k-anonymity format. Passwords are
+// never sent anywhere to be checked.
+//
+// The list is either a directory of range files, one per five character
+// hash prefix and named like "5BAA6.txt", each listing "SUFFIX:COUNT"
+// lines, or a single file of "HASH:COUNT" lines. Range files are read on
+// demand; a single file is loaded into memory, so it suits smaller lists.
+type BreachedPasswords struct {
+	dir    string
+	hashes map[string]bool
+}
+
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &BreachedPasswords{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		hashes[strings.ToUpper(hash)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading breached password list: %w", err)
+	}
+
+	return &BreachedPasswords{hashes: hashes}, nil
+}
+
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	if b.hashes != nil {
+		return b.hashes[hash], nil
+	}
+
+	prefix, suffix := hash[:5], hash[5:]
+	file, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(lineSuffix, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
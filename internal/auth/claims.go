@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeChirpsWrite = "chirps:write"
+	ScopeUsersRead   = "users:read"
+	ScopeUsersWrite  = "users:write"
+	ScopeUsersAdmin  = "users:admin"
+)
+
+// UserScopes is what a user gets by logging in with their password.
+// Moderators and admins also get ScopeUsersAdmin, which the admin API
+// requires on top of their role.
+var UserScopes = []string{ScopeChirpsWrite, ScopeUsersRead, ScopeUsersWrite}
+
+// TokenConfig is everything needed to issue and check access tokens.
+// Leeway is the clock skew allowed when checking exp, nbf and iat.
+type TokenConfig struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// Claims are the claims in a Chirpy access token. Scope is a space
+// separated list, as in OAuth 2.0. Version is the user's token version
+// when the token was issued; bumping it revokes every older token.
+// ClientID is set on tokens issued to an OAuth client rather than to the
+// user themselves. SessionID is the session the token was issued in, so
+// revoking the session revokes it too.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope     string `json:"scope,omitempty"`
+	Version   int32  `json:"ver"`
+	ClientID  string `json:"client_id,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+}
+
+// AccessToken is what a validated access token grants. Role and
+// EmailVerified aren't part of the token; they are looked up when the
+// token is checked, so changes take effect straight away. ClientID is set when the token was
+// issued to an OAuth client, SessionID when it was issued in a session
+// and APIKeyID when the request was made with an API key.
+type AccessToken struct {
+	UserID    uuid.UUID
+	Scopes    []string
+	Version   int32
+	Role      string
+	ClientID  uuid.NullUUID
+	SessionID uuid.NullUUID
+	APIKeyID  uuid.NullUUID
+
+	EmailVerified bool
+}
+
+// FirstParty reports whether the user is acting directly, having logged
+// in, rather than through an OAuth client or an API key.
+func (t AccessToken) FirstParty() bool {
+	return !t.ClientID.Valid && !t.APIKeyID.Valid
+}
+
+func (t AccessToken) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(t.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// A Hasher makes password hashes as PHC strings, which name the algorithm
+// and parameters they were made with. CheckPasswordHash can check a hash
+// from any Hasher, so the algorithm can be changed without a reset.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether a hash was made with another algorithm
+	// or other parameters than this Hasher would use.
+	NeedsRehash(hash string) bool
+	// MaxPasswordLength is the longest password in bytes the algorithm
+	// takes into account.
+	MaxPasswordLength() int
+}
+
+// BcryptHasher makes bcrypt hashes. bcrypt ignores everything after 72
+// bytes, so longer passwords are refused.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password")
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+func (h BcryptHasher) MaxPasswordLength() int {
+	return 72
+}
+
+// Argon2idHasher makes argon2id hashes. Memory is in KiB.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// argon2MaxPasswordLength only guards against huge passwords being
+	// used to tie up the server; argon2id itself has no limit.
+	argon2MaxPasswordLength = 1024
+	// The parameter limits keep a corrupted hash from tying up the
+	// server too. Memory is in KiB, so the most is 4 GiB.
+	argon2MaxMemory     = 4 * 1024 * 1024
+	argon2MaxIterations = 100
+)
+
+// Validate reports whether the hasher's parameters are ones argon2id can
+// use.
+func (h Argon2idHasher) Validate() error {
+	return argon2Params{
+		memory:      h.Memory,
+		iterations:  h.Iterations,
+		parallelism: h.Parallelism,
+	}.validate()
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	err := h.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password")
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+	return argon2Params{
+		memory:      h.Memory,
+		iterations:  h.Iterations,
+		parallelism: h.Parallelism,
+		salt:        salt,
+		key:         key,
+	}.String(), nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	params, err := parseArgon2id(hash)
+	return err != nil ||
+		params.memory != h.Memory ||
+		params.iterations != h.Iterations ||
+		params.parallelism != h.Parallelism ||
+		len(params.salt) != argon2SaltLength ||
+		len(params.key) != argon2KeyLength
+}
+
+func (h Argon2idHasher) MaxPasswordLength() int {
+	return argon2MaxPasswordLength
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// validate checks the parameters are in range, as argon2.IDKey panics on
+// some that aren't.
+func (p argon2Params) validate() error {
+	if p.iterations < 1 || p.iterations > argon2MaxIterations {
+		return fmt.Errorf("argon2id iterations must be between 1 and %d", argon2MaxIterations)
+	}
+	if p.parallelism < 1 {
+		return fmt.Errorf("argon2id parallelism must be at least 1")
+	}
+	if p.memory < 8*uint32(p.parallelism) || p.memory > argon2MaxMemory {
+		return fmt.Errorf("argon2id memory must be between 8 KiB per thread and %d KiB", argon2MaxMemory)
+	}
+	return nil
+}
+
+// String formats the hash as a PHC string:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func (p argon2Params) String() string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(p.salt),
+		base64.RawStdEncoding.EncodeToString(p.key),
+	)
+}
+
+func parseArgon2id(hash string) (argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return argon2Params{}, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return argon2Params{}, fmt.Errorf("unsupported argon2 version")
+	}
+
+	var params argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil {
+		return argon2Params{}, fmt.Errorf("malformed argon2id parameters")
+	}
+	err = params.validate()
+	if err != nil {
+		return argon2Params{}, err
+	}
+
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, fmt.Errorf("malformed argon2id salt")
+	}
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(params.key) == 0 {
+		return argon2Params{}, fmt.Errorf("malformed argon2id hash")
+	}
+
+	return params, nil
+}
+
+func checkArgon2id(password, hash string) error {
+	params, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	if len(password) > argon2MaxPasswordLength {
+		return fmt.Errorf("incorrect password")
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	if subtle.ConstantTimeCompare(key, params.key) != 1 {
+		return fmt.Errorf("incorrect password")
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2 is cheap enough to keep the tests fast.
+var testArgon2 = Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}
+
+const (
+	testSalt = "c2FsdHNhbHRzYWx0c2FsdA"
+	testKey  = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+)
+
+func TestParseArgon2id(t *testing.T) {
+	tests := []struct {
+		name    string
+		hash    string
+		want    argon2Params
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			hash: "$argon2id$v=19$m=19456,t=2,p=1$" + testSalt + "$" + testKey,
+			want: argon2Params{memory: 19456, iterations: 2, parallelism: 1},
+		},
+		{name: "bcrypt hash", hash: "$2a$10$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyz01234", wantErr: true},
+		{name: "argon2i", hash: "$argon2i$v=19$m=19456,t=2,p=1$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "old version", hash: "$argon2id$v=16$m=19456,t=2,p=1$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "missing part", hash: "$argon2id$v=19$m=19456,t=2,p=1$" + testSalt, wantErr: true},
+		{name: "malformed parameters", hash: "$argon2id$v=19$m=19456,p=1$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "zero iterations", hash: "$argon2id$v=19$m=19456,t=0,p=1$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "too many iterations", hash: "$argon2id$v=19$m=19456,t=101,p=1$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "zero parallelism", hash: "$argon2id$v=19$m=19456,t=2,p=0$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "parallelism overflow", hash: "$argon2id$v=19$m=19456,t=2,p=256$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "memory below 8 KiB per thread", hash: "$argon2id$v=19$m=31,t=2,p=4$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "too much memory", hash: "$argon2id$v=19$m=4194305,t=2,p=1$" + testSalt + "$" + testKey, wantErr: true},
+		{name: "bad salt", hash: "$argon2id$v=19$m=19456,t=2,p=1$!!!$" + testKey, wantErr: true},
+		{name: "empty key", hash: "$argon2id$v=19$m=19456,t=2,p=1$" + testSalt + "$", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseArgon2id(tt.hash)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseArgon2id(%q) = %+v, want an error", tt.hash, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgon2id(%q) returned error: %v", tt.hash, err)
+			}
+			if got.memory != tt.want.memory || got.iterations != tt.want.iterations || got.parallelism != tt.want.parallelism {
+				t.Errorf("parseArgon2id(%q) = m=%d,t=%d,p=%d, want m=%d,t=%d,p=%d", tt.hash,
+					got.memory, got.iterations, got.parallelism,
+					tt.want.memory, tt.want.iterations, tt.want.parallelism)
+			}
+			if len(got.salt) != argon2SaltLength || len(got.key) != argon2KeyLength {
+				t.Errorf("parseArgon2id(%q) salt and key lengths = %d, %d", tt.hash, len(got.salt), len(got.key))
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashRejectsBadParameters(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=19456,t=2,p=0$" + testSalt + "$" + testKey,
+		"$argon2id$v=19$m=19456,t=0,p=1$" + testSalt + "$" + testKey,
+		"$argon2id$v=19$m=7,t=1,p=1$" + testSalt + "$" + testKey,
+	} {
+		err := CheckPasswordHash("password", hash)
+		if err == nil {
+			t.Errorf("CheckPasswordHash accepted %q", hash)
+		}
+	}
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{name: "argon2id", hasher: testArgon2, prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{name: "bcrypt", hasher: BcryptHasher{Cost: bcrypt.MinCost}, prefix: "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password := "correct horse battery staple"
+			hash, err := tt.hasher.Hash(password)
+			if err != nil {
+				t.Fatalf("Hash returned error: %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("Hash = %q, want prefix %q", hash, tt.prefix)
+			}
+
+			if err := CheckPasswordHash(password, hash); err != nil {
+				t.Errorf("CheckPasswordHash with the right password returned error: %v", err)
+			}
+			if err := CheckPasswordHash("wrong password", hash); err == nil {
+				t.Error("CheckPasswordHash accepted the wrong password")
+			}
+
+			other, err := tt.hasher.Hash(password)
+			if err != nil {
+				t.Fatalf("Hash returned error: %v", err)
+			}
+			if other == hash {
+				t.Error("Hash gave the same hash twice, so the salt isn't random")
+			}
+		})
+	}
+}
+
+func TestArgon2idHasherRejectsBadParameters(t *testing.T) {
+	for _, hasher := range []Argon2idHasher{
+		{Memory: 64, Iterations: 0, Parallelism: 1},
+		{Memory: 64, Iterations: 1, Parallelism: 0},
+		{Memory: 15, Iterations: 1, Parallelism: 2},
+	} {
+		if err := hasher.Validate(); err == nil {
+			t.Errorf("%+v.Validate() returned no error", hasher)
+		}
+		if _, err := hasher.Hash("password"); err == nil {
+			t.Errorf("%+v.Hash returned no error", hasher)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := BcryptHasher{Cost: bcrypt.MinCost}.Hash("password")
+	if err != nil {
+		t.Fatalf("bcrypt Hash returned error: %v", err)
+	}
+	argon2Hash, err := testArgon2.Hash("password")
+	if err != nil {
+		t.Fatalf("argon2id Hash returned error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+		want   bool
+	}{
+		{name: "bcrypt to argon2id", hasher: testArgon2, hash: bcryptHash, want: true},
+		{name: "argon2id unchanged", hasher: testArgon2, hash: argon2Hash, want: false},
+		{name: "argon2id more memory", hasher: Argon2idHasher{Memory: 128, Iterations: 1, Parallelism: 1}, hash: argon2Hash, want: true},
+		{name: "argon2id more iterations", hasher: Argon2idHasher{Memory: 64, Iterations: 2, Parallelism: 1}, hash: argon2Hash, want: true},
+		{name: "argon2id more parallelism", hasher: Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 2}, hash: argon2Hash, want: true},
+		{name: "argon2id to bcrypt", hasher: BcryptHasher{Cost: bcrypt.MinCost}, hash: argon2Hash, want: true},
+		{name: "bcrypt unchanged", hasher: BcryptHasher{Cost: bcrypt.MinCost}, hash: bcryptHash, want: false},
+		{name: "bcrypt higher cost", hasher: BcryptHasher{Cost: bcrypt.MinCost + 1}, hash: bcryptHash, want: true},
+		{name: "locked password", hasher: testArgon2, hash: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
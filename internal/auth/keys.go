@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// A SigningKey is one key in a KeySet. Keys without a private half can
+// only verify; they are kept around so tokens signed before a rotation
+// stay valid until they expire.
+type SigningKey struct {
+	ID          string
+	Method      jwt.SigningMethod
+	ActiveFrom  time.Time
+	RetireAfter time.Time
+
+	signKey   interface{}
+	verifyKey interface{}
+	public    crypto.PublicKey
+}
+
+func (k SigningKey) canSign(now time.Time) bool {
+	return k.signKey != nil && !now.Before(k.ActiveFrom) && k.canVerify(now)
+}
+
+func (k SigningKey) canVerify(now time.Time) bool {
+	return k.RetireAfter.IsZero() || now.Before(k.RetireAfter)
+}
+
+// A KeySet holds every key Chirpy signs or verifies access tokens with.
+// Tokens are signed with the newest key whose active_from has passed, so
+// a rotation is scheduled by adding a key with a future active_from: it is
+// published in the JWKS straight away and takes over signing when due.
+type KeySet struct {
+	keys []SigningKey
+}
+
+// NewHMACKeySet signs and verifies with a single shared secret. Its tokens
+// carry no kid and the secret is never published.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{keys: []SigningKey{hmacKey(secret)}}
+}
+
+func hmacKey(secret string) SigningKey {
+	return SigningKey{
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+type keyManifest struct {
+	Keys []struct {
+		ID             string     `json:"kid"`
+		Algorithm      string     `json:"alg"`
+		PrivateKeyFile string     `json:"private_key_file"`
+		PublicKeyFile  string     `json:"public_key_file"`
+		ActiveFrom     time.Time  `json:"active_from"`
+		RetireAfter    *time.Time `json:"retire_after"`
+	} `json:"keys"`
+}
+
+// LoadKeySet reads a JSON manifest listing RS256 and EdDSA keys as PEM
+// files, relative to the manifest. If legacySecret is set, tokens without
+// a kid are still verified with it so HS256 tokens issued before the
+// switch keep working; it is never used to sign.
+func LoadKeySet(manifestPath, legacySecret string) (*KeySet, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest keyManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key manifest: %w", err)
+	}
+
+	keySet := &KeySet{}
+	seen := map[string]bool{}
+	for _, entry := range manifest.Keys {
+		if entry.ID == "" {
+			return nil, fmt.Errorf("key manifest entry is missing a kid")
+		}
+		if seen[entry.ID] {
+			return nil, fmt.Errorf("duplicate kid %q", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		key := SigningKey{ID: entry.ID, ActiveFrom: entry.ActiveFrom}
+		if entry.RetireAfter != nil {
+			key.RetireAfter = *entry.RetireAfter
+		}
+
+		switch entry.Algorithm {
+		case "RS256":
+			key.Method = jwt.SigningMethodRS256
+		case "EdDSA":
+			key.Method = jwt.SigningMethodEdDSA
+		default:
+			return nil, fmt.Errorf("key %q: unsupported alg %q", entry.ID, entry.Algorithm)
+		}
+
+		switch {
+		case entry.PrivateKeyFile != "":
+			err = key.loadPrivateKey(resolveKeyPath(manifestPath, entry.PrivateKeyFile))
+		case entry.PublicKeyFile != "":
+			err = key.loadPublicKey(resolveKeyPath(manifestPath, entry.PublicKeyFile))
+		default:
+			err = fmt.Errorf("no key file given")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+
+		keySet.keys = append(keySet.keys, key)
+	}
+
+	if len(keySet.keys) == 0 {
+		return nil, fmt.Errorf("key manifest has no keys")
+	}
+
+	// Newest first, so the signing key is the first one that can sign.
+	sort.SliceStable(keySet.keys, func(i, j int) bool {
+		return keySet.keys[i].ActiveFrom.After(keySet.keys[j].ActiveFrom)
+	})
+
+	if legacySecret != "" {
+		legacyKey := hmacKey(legacySecret)
+		legacyKey.signKey = nil
+		keySet.keys = append(keySet.keys, legacyKey)
+	}
+
+	return keySet, nil
+}
+
+func resolveKeyPath(manifestPath, keyPath string) string {
+	if filepath.IsAbs(keyPath) {
+		return keyPath
+	}
+	return filepath.Join(filepath.Dir(manifestPath), keyPath)
+}
+
+func (k *SigningKey) loadPrivateKey(path string) error {
+	block, err := readPEM(path)
+	if err != nil {
+		return err
+	}
+
+	var privateKey interface{}
+	privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing private key: %w", err)
+	}
+
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if k.Method != jwt.SigningMethodRS256 {
+			return fmt.Errorf("RSA key used with alg %s", k.Method.Alg())
+		}
+		k.signKey = privateKey
+		k.verifyKey = &privateKey.PublicKey
+		k.public = &privateKey.PublicKey
+	case ed25519.PrivateKey:
+		if k.Method != jwt.SigningMethodEdDSA {
+			return fmt.Errorf("Ed25519 key used with alg %s", k.Method.Alg())
+		}
+		k.signKey = privateKey
+		k.verifyKey = privateKey.Public()
+		k.public = privateKey.Public()
+	default:
+		return fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+	return nil
+}
+
+func (k *SigningKey) loadPublicKey(path string) error {
+	block, err := readPEM(path)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing public key: %w", err)
+	}
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		if k.Method != jwt.SigningMethodRS256 {
+			return fmt.Errorf("RSA key used with alg %s", k.Method.Alg())
+		}
+	case ed25519.PublicKey:
+		if k.Method != jwt.SigningMethodEdDSA {
+			return fmt.Errorf("Ed25519 key used with alg %s", k.Method.Alg())
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	k.verifyKey = publicKey
+	k.public = publicKey
+	return nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+	return block, nil
+}
+
+func (ks *KeySet) signingKey(now time.Time) (SigningKey, error) {
+	for _, key := range ks.keys {
+		if key.canSign(now) {
+			return key, nil
+		}
+	}
+	return SigningKey{}, fmt.Errorf("no active signing key")
+}
+
+func (ks *KeySet) verificationKey(kid string, now time.Time) (SigningKey, error) {
+	for _, key := range ks.keys {
+		if key.ID == kid && key.canVerify(now) {
+			return key, nil
+		}
+	}
+	return SigningKey{}, fmt.Errorf("unknown signing key")
+}
+
+// keyFunc picks the key a token claims to be signed with and refuses any
+// algorithm other than the one that key was configured for.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := ks.verificationKey(kid, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("incorrect signing method")
+	}
+	return key.verifyKey, nil
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public half of every key that can still verify a token,
+// including scheduled keys that have not started signing yet. HMAC keys
+// are never included.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range ks.keys {
+		if key.public == nil || !key.canVerify(now) {
+			continue
+		}
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Method.Alg(),
+		}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
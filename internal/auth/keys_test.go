@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type testSigningKey struct {
+	kid         string
+	alg         string
+	activeFrom  time.Time
+	retireAfter *time.Time
+	// publicOnly writes only the public half, as after a key is rotated
+	// out of signing.
+	publicOnly bool
+}
+
+// writeKeySet generates a key for each entry and loads them through a
+// manifest the way the server does. It returns the public keys by kid.
+func writeKeySet(t *testing.T, legacySecret string, keys ...testSigningKey) (*KeySet, map[string]interface{}) {
+	t.Helper()
+	dir := t.TempDir()
+
+	type manifestEntry struct {
+		ID             string     `json:"kid"`
+		Algorithm      string     `json:"alg"`
+		PrivateKeyFile string     `json:"private_key_file,omitempty"`
+		PublicKeyFile  string     `json:"public_key_file,omitempty"`
+		ActiveFrom     time.Time  `json:"active_from"`
+		RetireAfter    *time.Time `json:"retire_after"`
+	}
+	var manifest struct {
+		Keys []manifestEntry `json:"keys"`
+	}
+	publicKeys := map[string]interface{}{}
+
+	for _, key := range keys {
+		var privateKey, publicKey interface{}
+		switch key.alg {
+		case "RS256":
+			rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			privateKey, publicKey = rsaKey, &rsaKey.PublicKey
+		case "EdDSA":
+			public, private, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			privateKey, publicKey = private, public
+		default:
+			t.Fatalf("unsupported alg %s", key.alg)
+		}
+		publicKeys[key.kid] = publicKey
+
+		entry := manifestEntry{ID: key.kid, Algorithm: key.alg, ActiveFrom: key.activeFrom, RetireAfter: key.retireAfter}
+		if key.publicOnly {
+			der, err := x509.MarshalPKIXPublicKey(publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry.PublicKeyFile = key.kid + ".pub.pem"
+			writePEM(t, filepath.Join(dir, entry.PublicKeyFile), "PUBLIC KEY", der)
+		} else {
+			der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry.PrivateKeyFile = key.kid + ".pem"
+			writePEM(t, filepath.Join(dir, entry.PrivateKeyFile), "PRIVATE KEY", der)
+		}
+		manifest.Keys = append(manifest.Keys, entry)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(dir, "keys.json")
+	err = os.WriteFile(manifestPath, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keySet, err := LoadKeySet(manifestPath, legacySecret)
+	if err != nil {
+		t.Fatalf("LoadKeySet returned error: %v", err)
+	}
+	return keySet, publicKeys
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySetSigningKeyRotation(t *testing.T) {
+	now := time.Now()
+	retired := now.Add(-time.Hour)
+	retiring := now.Add(2 * time.Hour)
+
+	keySet, _ := writeKeySet(t, "",
+		testSigningKey{kid: "retired", alg: "EdDSA", activeFrom: now.Add(-72 * time.Hour), retireAfter: &retired},
+		testSigningKey{kid: "current", alg: "EdDSA", activeFrom: now.Add(-24 * time.Hour), retireAfter: &retiring},
+		testSigningKey{kid: "next", alg: "EdDSA", activeFrom: now.Add(time.Hour)},
+		testSigningKey{kid: "verify-only", alg: "EdDSA", activeFrom: now.Add(3 * time.Hour), publicOnly: true},
+	)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "before the next key is active", at: now, want: "current"},
+		{name: "once the next key is active", at: now.Add(90 * time.Minute), want: "next"},
+		{name: "after the current key retires", at: now.Add(150 * time.Minute), want: "next"},
+		{name: "a public key only never signs", at: now.Add(4 * time.Hour), want: "next"},
+		{name: "long before any rotation", at: now.Add(-48 * time.Hour), want: "retired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := keySet.signingKey(tt.at)
+			if err != nil {
+				t.Fatalf("signingKey returned error: %v", err)
+			}
+			if key.ID != tt.want {
+				t.Errorf("signingKey = %q, want %q", key.ID, tt.want)
+			}
+		})
+	}
+
+	_, err := keySet.signingKey(now.Add(-100 * time.Hour))
+	if err == nil {
+		t.Error("signingKey found a key before any was active")
+	}
+}
+
+func TestKeySetKeyFunc(t *testing.T) {
+	now := time.Now()
+	retired := now.Add(-time.Hour)
+	keySet, publicKeys := writeKeySet(t, "legacy-secret",
+		testSigningKey{kid: "rsa", alg: "RS256", activeFrom: now.Add(-time.Hour)},
+		testSigningKey{kid: "ed", alg: "EdDSA", activeFrom: now.Add(-2 * time.Hour)},
+		testSigningKey{kid: "scheduled", alg: "EdDSA", activeFrom: now.Add(time.Hour)},
+		testSigningKey{kid: "retired", alg: "EdDSA", activeFrom: now.Add(-48 * time.Hour), retireAfter: &retired},
+	)
+
+	tests := []struct {
+		name    string
+		kid     string
+		method  jwt.SigningMethod
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "rsa key", kid: "rsa", method: jwt.SigningMethodRS256, want: publicKeys["rsa"]},
+		{name: "ed25519 key", kid: "ed", method: jwt.SigningMethodEdDSA, want: publicKeys["ed"]},
+		{name: "scheduled key verifies early", kid: "scheduled", method: jwt.SigningMethodEdDSA, want: publicKeys["scheduled"]},
+		{name: "legacy hmac without kid", kid: "", method: jwt.SigningMethodHS256, want: []byte("legacy-secret")},
+		{name: "retired key", kid: "retired", method: jwt.SigningMethodEdDSA, wantErr: true},
+		{name: "unknown kid", kid: "missing", method: jwt.SigningMethodEdDSA, wantErr: true},
+		{name: "hmac with an rsa kid", kid: "rsa", method: jwt.SigningMethodHS256, wantErr: true},
+		{name: "hmac with an ed25519 kid", kid: "ed", method: jwt.SigningMethodHS256, wantErr: true},
+		{name: "rsa with an ed25519 kid", kid: "ed", method: jwt.SigningMethodRS256, wantErr: true},
+		{name: "rsa without kid", kid: "", method: jwt.SigningMethodRS256, wantErr: true},
+		{name: "none", kid: "rsa", method: jwt.SigningMethodNone, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.New(tt.method)
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			got, err := keySet.keyFunc(token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("keyFunc returned %T, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("keyFunc returned error: %v", err)
+			}
+			switch want := tt.want.(type) {
+			case []byte:
+				if string(got.([]byte)) != string(want) {
+					t.Errorf("keyFunc returned the wrong secret")
+				}
+			case *rsa.PublicKey:
+				if !want.Equal(got) {
+					t.Errorf("keyFunc returned the wrong key")
+				}
+			case ed25519.PublicKey:
+				if !want.Equal(got) {
+					t.Errorf("keyFunc returned the wrong key")
+				}
+			}
+		})
+	}
+}
+
+// TestValidateJWTRejectsAlgorithmConfusion signs a token with HS256 using
+// the published RSA public key as the secret, the classic attack on
+// verifiers that trust the token's alg header.
+func TestValidateJWTRejectsAlgorithmConfusion(t *testing.T) {
+	keySet, publicKeys := writeKeySet(t, "",
+		testSigningKey{kid: "rsa", alg: "RS256", activeFrom: time.Now().Add(-time.Hour)},
+	)
+	tokenConfig := TokenConfig{Keys: keySet, Issuer: "chirpy", Audience: "chirpy"}
+
+	userID := uuid.New()
+	valid, err := MakeJWT(userID, tokenConfig, time.Hour, nil, 0, uuid.NullUUID{}, uuid.NullUUID{})
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+	accessToken, err := ValidateJWT(valid, tokenConfig)
+	if err != nil || accessToken.UserID != userID {
+		t.Fatalf("ValidateJWT of a genuine token = %v, %v", accessToken.UserID, err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKeys["rsa"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{"chirpy"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			Subject:   userID.String(),
+		},
+	})
+	forged.Header["kid"] = "rsa"
+	for _, secret := range [][]byte{der, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})} {
+		tokenString, err := forged.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ValidateJWT(tokenString, tokenConfig)
+		if err == nil {
+			t.Error("ValidateJWT accepted an HS256 token signed with the RSA public key")
+		}
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	now := time.Now()
+	retired := now.Add(-time.Hour)
+	keySet, publicKeys := writeKeySet(t, "legacy-secret",
+		testSigningKey{kid: "rsa", alg: "RS256", activeFrom: now.Add(-time.Hour)},
+		testSigningKey{kid: "ed", alg: "EdDSA", activeFrom: now.Add(-2 * time.Hour), publicOnly: true},
+		testSigningKey{kid: "scheduled", alg: "EdDSA", activeFrom: now.Add(time.Hour)},
+		testSigningKey{kid: "retired", alg: "EdDSA", activeFrom: now.Add(-48 * time.Hour), retireAfter: &retired},
+	)
+
+	jwks := keySet.JWKS()
+	got := map[string]JWK{}
+	for _, jwk := range jwks.Keys {
+		got[jwk.KeyID] = jwk
+	}
+
+	if len(got) != 3 {
+		t.Errorf("JWKS has keys %v, want rsa, ed and scheduled", got)
+	}
+	if _, ok := got["retired"]; ok {
+		t.Error("JWKS published a retired key")
+	}
+	if _, ok := got[""]; ok {
+		t.Error("JWKS published the HMAC secret")
+	}
+
+	rsaKey := publicKeys["rsa"].(*rsa.PublicKey)
+	if jwk := got["rsa"]; jwk.KeyType != "RSA" || jwk.Algorithm != "RS256" || jwk.Use != "sig" ||
+		jwk.N != base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()) || jwk.E != "AQAB" {
+		t.Errorf("rsa JWK = %+v", jwk)
+	}
+	for _, kid := range []string{"ed", "scheduled"} {
+		edKey := publicKeys[kid].(ed25519.PublicKey)
+		if jwk := got[kid]; jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || jwk.Algorithm != "EdDSA" ||
+			jwk.X != base64.RawURLEncoding.EncodeToString(edKey) {
+			t.Errorf("%s JWK = %+v", kid, jwk)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A PasswordPolicy decides which new passwords are acceptable. Existing
+// passwords are never checked against it, so tightening it doesn't lock
+// anyone out.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength is in bytes, and comes from the Hasher in use.
+	MaxLength      int
+	MinEntropyBits float64
+	// Breached, if set, rejects passwords known from breaches.
+	Breached *BreachedPasswords
+}
+
+// WeakPasswordError is returned for a password the policy rejects. Any
+// other error from Check means the check itself failed.
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return e.Reason
+}
+
+func (p PasswordPolicy) Check(password, email string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &WeakPasswordError{fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return &WeakPasswordError{fmt.Sprintf("password must be at most %d bytes", p.MaxLength)}
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	localPart, _, _ := strings.Cut(email, "@")
+	lowerPassword := strings.ToLower(password)
+	if email != "" && (lowerPassword == email || lowerPassword == localPart) {
+		return &WeakPasswordError{"password must not be your email address"}
+	}
+
+	if EstimateEntropy(password) < p.MinEntropyBits {
+		return &WeakPasswordError{"password is too easy to guess; try a longer one"}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return &WeakPasswordError{"password has appeared in a data breach; choose a different one"}
+		}
+	}
+
+	return nil
+}
+
+// EstimateEntropy gives a rough strength in bits: the size of the
+// character classes used, raised to the number of characters that aren't
+// a repeat or a step up or down from the one before, as in "aaaa" or
+// "1234".
+func EstimateEntropy(password string) float64 {
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	effectiveLength := 0
+	var previous rune = -1
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			hasLower = true
+		case c >= 'A' && c <= 'Z':
+			hasUpper = true
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+		if previous < 0 || (c != previous && c != previous+1 && c != previous-1) {
+			effectiveLength++
+		}
+		previous = c
+	}
+
+	poolSize := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{
+		{hasLower, 26},
+		{hasUpper, 26},
+		{hasDigit, 10},
+		{hasSymbol, 33},
+		{hasOther, 100},
+	} {
+		if class.used {
+			poolSize += class.size
+		}
+	}
+	if poolSize == 0 {
+		return 0
+	}
+
+	return float64(effectiveLength) * math.Log2(float64(poolSize))
+}
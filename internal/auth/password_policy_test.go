@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// passwordSHA1 is the SHA-1 of "password", the usual first entry in a
+// breached password list.
+const passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func TestEstimateEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{password: "", want: 0},
+		{password: "a", want: math.Log2(26)},
+		{password: "aaaaaaaa", want: math.Log2(26)},
+		{password: "abcdefgh", want: math.Log2(26)},
+		{password: "hgfedcba", want: math.Log2(26)},
+		{password: "12345678", want: math.Log2(10)},
+		{password: "password", want: 7 * math.Log2(26)},
+		{password: "abc1", want: 2 * math.Log2(36)},
+		{password: "aA1!", want: 4 * math.Log2(95)},
+		{password: "Zz", want: 2 * math.Log2(52)},
+		{password: "çé", want: 2 * math.Log2(100)},
+	}
+
+	for _, tt := range tests {
+		got := EstimateEntropy(tt.password)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateEntropy(%q) = %.2f, want %.2f", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	dir := t.TempDir()
+
+	listPath := filepath.Join(dir, "list.txt")
+	writeFile(t, listPath, "not a hash\n"+passwordSHA1+":3861493\n")
+
+	rangeDir := filepath.Join(dir, "ranges")
+	err := os.Mkdir(rangeDir, 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rangeDir, passwordSHA1[:5]+".txt"),
+		"0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n"+
+			"1e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\r\n")
+
+	for _, path := range []string{listPath, rangeDir} {
+		breached, err := LoadBreachedPasswords(path)
+		if err != nil {
+			t.Fatalf("LoadBreachedPasswords(%s) returned error: %v", path, err)
+		}
+		for password, want := range map[string]bool{
+			"password":                     true,
+			"Password":                     false,
+			"correct horse battery staple": false,
+		} {
+			got, err := breached.Contains(password)
+			if err != nil {
+				t.Fatalf("Contains(%q) returned error: %v", password, err)
+			}
+			if got != want {
+				t.Errorf("%s: Contains(%q) = %v, want %v", filepath.Base(path), password, got, want)
+			}
+		}
+	}
+
+	_, err = LoadBreachedPasswords(filepath.Join(dir, "missing.txt"))
+	if err == nil {
+		t.Error("LoadBreachedPasswords of a missing file returned no error")
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "list.txt")
+	// A long but breached password, so only the breach check rejects it.
+	breachedPassword := "Tr0ub4dor&3-horse-staple"
+	writeFile(t, listPath, sha1Hex(breachedPassword)+":12\n")
+	breached, err := LoadBreachedPasswords(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 72, MinEntropyBits: 40, Breached: breached}
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		wantWeak bool
+	}{
+		{name: "strong", password: "correct horse battery staple", email: "a@example.com"},
+		{name: "too short", password: "Ab1!", wantWeak: true},
+		{name: "too long", password: string(make([]byte, 73)), wantWeak: true},
+		{name: "email", password: "Someone@Example.com", email: "someone@example.com", wantWeak: true},
+		{name: "email local part", password: "someone.else", email: "Someone.Else@example.com", wantWeak: true},
+		{name: "low entropy", password: "aaaaaaaaaaaaaaaa", wantWeak: true},
+		{name: "sequence", password: "abcdefghijklmnop", wantWeak: true},
+		{name: "breached", password: breachedPassword, wantWeak: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, tt.email)
+			var weak *WeakPasswordError
+			if tt.wantWeak {
+				if !errors.As(err, &weak) {
+					t.Errorf("Check(%q) = %v, want a WeakPasswordError", tt.password, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Check(%q) returned error: %v", tt.password, err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(contents), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
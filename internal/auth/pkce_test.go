@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+// The verifier and challenge from RFC 7636 Appendix B.
+const (
+	rfc7636Verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfc7636Challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		wantErr   bool
+	}{
+		{name: "RFC 7636 Appendix B", verifier: rfc7636Verifier, challenge: rfc7636Challenge},
+		{name: "wrong verifier", verifier: strings.Replace(rfc7636Verifier, "d", "e", 1), challenge: rfc7636Challenge, wantErr: true},
+		{name: "plain method", verifier: rfc7636Verifier, challenge: rfc7636Verifier, wantErr: true},
+		{name: "padded challenge", verifier: rfc7636Verifier, challenge: rfc7636Challenge + "=", wantErr: true},
+		{name: "too short", verifier: strings.Repeat("a", 42), challenge: rfc7636Challenge, wantErr: true},
+		{name: "too long", verifier: strings.Repeat("a", 129), challenge: rfc7636Challenge, wantErr: true},
+		{name: "invalid character", verifier: rfc7636Verifier[:42] + "+", challenge: rfc7636Challenge, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyPKCE(tt.verifier, tt.challenge)
+			if tt.wantErr && err == nil {
+				t.Errorf("VerifyPKCE(%q, %q) returned no error", tt.verifier, tt.challenge)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("VerifyPKCE(%q, %q) returned error: %v", tt.verifier, tt.challenge, err)
+			}
+		})
+	}
+}
+
+func TestValidateCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		wantErr   bool
+	}{
+		{name: "RFC 7636 Appendix B", challenge: rfc7636Challenge},
+		{name: "empty", challenge: "", wantErr: true},
+		{name: "padded", challenge: rfc7636Challenge + "=", wantErr: true},
+		{name: "standard base64", challenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw+cM", wantErr: true},
+		{name: "too short", challenge: rfc7636Challenge[:40], wantErr: true},
+		{name: "plain verifier", challenge: rfc7636Verifier + "AAAA", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCodeChallenge(tt.challenge)
+			if tt.wantErr && err == nil {
+				t.Errorf("ValidateCodeChallenge(%q) returned no error", tt.challenge)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("ValidateCodeChallenge(%q) returned error: %v", tt.challenge, err)
+			}
+		})
+	}
+}
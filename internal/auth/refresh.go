@@ -2,8 +2,13 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
 func MakeRefreshToken() (string, error) {
@@ -14,3 +19,33 @@ func MakeRefreshToken() (string, error) {
 	}
 	return hex.EncodeToString(refreshToken), nil
 }
+
+// FormatRefreshToken joins the id used to look a refresh token up with the
+// secret that proves it. Only the id and a hash of the secret are stored.
+func FormatRefreshToken(id uuid.UUID, secret string) string {
+	return id.String() + "." + secret
+}
+
+func ParseRefreshToken(token string) (uuid.UUID, string, error) {
+	idString, secret, found := strings.Cut(token, ".")
+	if !found || secret == "" {
+		return uuid.Nil, "", fmt.Errorf("malformed refresh token")
+	}
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("malformed refresh token")
+	}
+	return id, secret, nil
+}
+
+func HashRefreshToken(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func CheckRefreshTokenHash(secret, hash string) error {
+	if subtle.ConstantTimeCompare([]byte(HashRefreshToken(secret)), []byte(hash)) != 1 {
+		return fmt.Errorf("incorrect refresh token")
+	}
+	return nil
+}
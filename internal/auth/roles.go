@@ -0,0 +1,26 @@
+package auth
+
+// Roles, from least to most trusted. Each role can do everything the ones
+// before it can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole reports whether role is at least as trusted as minRole.
+func HasRole(role, minRole string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[minRole]
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 Appendix B,
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors are the SHA-1 test vectors from RFC 6238 Appendix B. The
+// RFC gives 8 digit codes; these are their last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{unix: 59, code: "287082"},
+	{unix: 1111111109, code: "081804"},
+	{unix: 1111111111, code: "050471"},
+	{unix: 1234567890, code: "005924"},
+	{unix: 2000000000, code: "279037"},
+	{unix: 20000000000, code: "353130"},
+}
+
+func TestHOTPRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, tt := range rfc6238Vectors {
+		if got := hotp(key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("hotp at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		counter, err := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Errorf("ValidateTOTP(%s) at %d returned error: %v", tt.code, tt.unix, err)
+			continue
+		}
+		if counter != tt.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s) at %d = counter %d, want %d", tt.code, tt.unix, counter, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	// 1111111111 falls in the same period as 1111111109, so its code is
+	// checked one period either side.
+	const unix, code = 1111111111, "050471"
+	counter := int64(unix / totpPeriod)
+
+	tests := []struct {
+		name    string
+		secret  string
+		code    string
+		now     time.Time
+		want    int64
+		wantErr bool
+	}{
+		{name: "on time", secret: rfc6238Secret, code: code, now: time.Unix(unix, 0), want: counter},
+		{name: "one period late", secret: rfc6238Secret, code: code, now: time.Unix(unix+totpPeriod, 0), want: counter},
+		{name: "one period early", secret: rfc6238Secret, code: code, now: time.Unix(unix-totpPeriod, 0), want: counter},
+		{name: "two periods late", secret: rfc6238Secret, code: code, now: time.Unix(unix+2*totpPeriod, 0), wantErr: true},
+		{name: "two periods early", secret: rfc6238Secret, code: code, now: time.Unix(unix-2*totpPeriod, 0), wantErr: true},
+		{name: "spaces", secret: rfc6238Secret, code: "050 471", now: time.Unix(unix, 0), want: counter},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: code, now: time.Unix(unix, 0), want: counter},
+		{name: "wrong code", secret: rfc6238Secret, code: "050472", now: time.Unix(unix, 0), wantErr: true},
+		{name: "eight digits", secret: rfc6238Secret, code: "14050471", now: time.Unix(unix, 0), wantErr: true},
+		{name: "too short", secret: rfc6238Secret, code: "05047", now: time.Unix(unix, 0), wantErr: true},
+		{name: "bad secret", secret: "not base32!", code: code, now: time.Unix(unix, 0), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateTOTP(tt.secret, tt.code, tt.now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ValidateTOTP(%q) = %d, want an error", tt.code, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateTOTP(%q) returned error: %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateTOTP(%q) = %d, want %d", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -7,22 +7,43 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const chirpHasDependents = `-- name: ChirpHasDependents :one
+SELECT EXISTS (
+	SELECT 1 FROM chirps
+	WHERE in_reply_to = $1::uuid
+	OR (referenced_chirp_id = $1::uuid AND kind = 'quote')
+)
+`
+
+func (q *Queries) ChirpHasDependents(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpHasDependents, chirpID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), $1, $2, $3, $4)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, kind, referenced_chirp_id)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id
 `
 
 type CreateChirpParams struct {
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Body              string
+	UserID            uuid.UUID
+	InReplyTo         uuid.NullUUID
+	ThreadID          uuid.NullUUID
+	Kind              string
+	ReferencedChirpID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -31,6 +52,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.InReplyTo,
+		arg.ThreadID,
+		arg.Kind,
+		arg.ReferencedChirpID,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -39,6 +64,12 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
 	)
 	return i, err
 }
@@ -46,7 +77,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 const deleteChirpByID = `-- name: DeleteChirpByID :one
 DELETE FROM chirps
 WHERE id = $1
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id
 `
 
 func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -58,17 +89,35 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (Chirp, err
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
 	)
 	return i, err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+const deleteRechirpsOf = `-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps
+WHERE referenced_chirp_id = $1::uuid
+AND kind = 'rechirp'
+`
+
+func (q *Queries) DeleteRechirpsOf(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRechirpsOf, chirpID)
+	return err
+}
+
+const getAllChirpsByAuthor = `-- name: GetAllChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE user_id = $1
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirpsByAuthor, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +131,12 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
 		); err != nil {
 			return nil, err
 		}
@@ -96,14 +151,38 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getAllChirpsByAuthor = `-- name: GetAllChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE id = $1
 `
 
-func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsByAuthor, userID)
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
+	)
+	return i, err
+}
+
+const getChirpReplies = `-- name: GetChirpReplies :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE in_reply_to = $1::uuid
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) GetChirpReplies(ctx context.Context, chirpID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpReplies, chirpID)
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +196,12 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
 		); err != nil {
 			return nil, err
 		}
@@ -131,13 +216,427 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 	return items, nil
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+const getChirpThread = `-- name: GetChirpThread :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE id = $1 OR thread_id = $1
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) GetChirpThread(ctx context.Context, threadID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpThread, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirpStats = `-- name: GetRechirpStats :many
+SELECT referenced_chirp_id::uuid AS chirp_id,
+	COUNT(*) FILTER (WHERE kind = 'rechirp') AS rechirp_count,
+	COUNT(*) FILTER (WHERE kind = 'quote') AS quote_count,
+	COALESCE(BOOL_OR(kind = 'rechirp' AND user_id = $1::uuid), FALSE)::boolean AS rechirped_by_me
+FROM chirps
+WHERE referenced_chirp_id = ANY($2::uuid[])
+AND deleted_at IS NULL
+GROUP BY referenced_chirp_id
+`
+
+type GetRechirpStatsParams struct {
+	ViewerID uuid.NullUUID
+	ChirpIds []uuid.UUID
+}
+
+type GetRechirpStatsRow struct {
+	ChirpID       uuid.UUID
+	RechirpCount  int64
+	QuoteCount    int64
+	RechirpedByMe bool
+}
+
+func (q *Queries) GetRechirpStats(ctx context.Context, arg GetRechirpStatsParams) ([]GetRechirpStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRechirpStats, arg.ViewerID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRechirpStatsRow
+	for rows.Next() {
+		var i GetRechirpStatsRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.RechirpedByMe,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAscending = `-- name: ListChirpsAscending :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE deleted_at IS NULL
+AND (cardinality($1::uuid[]) = 0 OR user_id = ANY($1::uuid[]))
+AND NOT (user_id = ANY($2::uuid[]))
+AND ($3::timestamp IS NULL OR created_at >= $3::timestamp)
+AND ($4::timestamp IS NULL OR created_at < $4::timestamp)
+AND ($5::timestamp IS NULL
+	OR (created_at, id) > ($5::timestamp, $6::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $7
+`
+
+type ListChirpsAscendingParams struct {
+	AuthorIds        []uuid.UUID
+	ExcludeAuthorIds []uuid.UUID
+	Since            sql.NullTime
+	Until            sql.NullTime
+	CursorCreatedAt  sql.NullTime
+	CursorID         uuid.NullUUID
+	PageLimit        int32
+}
+
+func (q *Queries) ListChirpsAscending(ctx context.Context, arg ListChirpsAscendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAscending,
+		pq.Array(arg.AuthorIds),
+		pq.Array(arg.ExcludeAuthorIds),
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDescending = `-- name: ListChirpsDescending :many
+SELECT id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id FROM chirps
+WHERE deleted_at IS NULL
+AND (cardinality($1::uuid[]) = 0 OR user_id = ANY($1::uuid[]))
+AND NOT (user_id = ANY($2::uuid[]))
+AND ($3::timestamp IS NULL OR created_at >= $3::timestamp)
+AND ($4::timestamp IS NULL OR created_at < $4::timestamp)
+AND ($5::timestamp IS NULL
+	OR (created_at, id) < ($5::timestamp, $6::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $7
+`
+
+type ListChirpsDescendingParams struct {
+	AuthorIds        []uuid.UUID
+	ExcludeAuthorIds []uuid.UUID
+	Since            sql.NullTime
+	Until            sql.NullTime
+	CursorCreatedAt  sql.NullTime
+	CursorID         uuid.NullUUID
+	PageLimit        int32
+}
+
+func (q *Queries) ListChirpsDescending(ctx context.Context, arg ListChirpsDescendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDescending,
+		pq.Array(arg.AuthorIds),
+		pq.Array(arg.ExcludeAuthorIds),
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.BodyTsv,
+			&i.InReplyTo,
+			&i.ThreadID,
+			&i.DeletedAt,
+			&i.Kind,
+			&i.ReferencedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.thread_id, chirps.deleted_at, chirps.kind, chirps.referenced_chirp_id, ts_rank(body_tsv, to_tsquery('english', $1::text))::real AS rank
+FROM chirps
+WHERE deleted_at IS NULL
+AND body_tsv @@ to_tsquery('english', $1::text)
+AND (cardinality($2::uuid[]) = 0 OR user_id = ANY($2::uuid[]))
+AND NOT (user_id = ANY($3::uuid[]))
+AND ($4::real IS NULL
+	OR (ts_rank(body_tsv, to_tsquery('english', $1::text)), created_at, id)
+	< ($4::real, $5::timestamp, $6::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT $7
+`
+
+type SearchChirpsParams struct {
+	Query            string
+	AuthorIds        []uuid.UUID
+	ExcludeAuthorIds []uuid.UUID
+	CursorRank       sql.NullFloat64
+	CursorCreatedAt  sql.NullTime
+	CursorID         uuid.NullUUID
+	PageLimit        int32
+}
+
+type SearchChirpsRow struct {
+	Chirp Chirp
+	Rank  float32
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		pq.Array(arg.AuthorIds),
+		pq.Array(arg.ExcludeAuthorIds),
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ThreadID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferencedChirpID,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirpsReverse = `-- name: SearchChirpsReverse :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.body_tsv, chirps.in_reply_to, chirps.thread_id, chirps.deleted_at, chirps.kind, chirps.referenced_chirp_id, ts_rank(body_tsv, to_tsquery('english', $1::text))::real AS rank
+FROM chirps
+WHERE deleted_at IS NULL
+AND body_tsv @@ to_tsquery('english', $1::text)
+AND (cardinality($2::uuid[]) = 0 OR user_id = ANY($2::uuid[]))
+AND NOT (user_id = ANY($3::uuid[]))
+AND ($4::real IS NULL
+	OR (ts_rank(body_tsv, to_tsquery('english', $1::text)), created_at, id)
+	> ($4::real, $5::timestamp, $6::uuid))
+ORDER BY rank ASC, created_at ASC, id ASC
+LIMIT $7
+`
+
+type SearchChirpsReverseParams struct {
+	Query            string
+	AuthorIds        []uuid.UUID
+	ExcludeAuthorIds []uuid.UUID
+	CursorRank       sql.NullFloat64
+	CursorCreatedAt  sql.NullTime
+	CursorID         uuid.NullUUID
+	PageLimit        int32
+}
+
+type SearchChirpsReverseRow struct {
+	Chirp Chirp
+	Rank  float32
+}
+
+func (q *Queries) SearchChirpsReverse(ctx context.Context, arg SearchChirpsReverseParams) ([]SearchChirpsReverseRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsReverse,
+		arg.Query,
+		pq.Array(arg.AuthorIds),
+		pq.Array(arg.ExcludeAuthorIds),
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsReverseRow
+	for rows.Next() {
+		var i SearchChirpsReverseRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.BodyTsv,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ThreadID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Kind,
+			&i.Chirp.ReferencedChirpID,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirpByID = `-- name: TombstoneChirpByID :one
+UPDATE chirps
+SET body = '',
+	deleted_at = now(),
+	updated_at = now()
 WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, body_tsv, in_reply_to, thread_id, deleted_at, kind, referenced_chirp_id
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+func (q *Queries) TombstoneChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, tombstoneChirpByID, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -145,6 +644,12 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.BodyTsv,
+		&i.InReplyTo,
+		&i.ThreadID,
+		&i.DeletedAt,
+		&i.Kind,
+		&i.ReferencedChirpID,
 	)
 	return i, err
 }
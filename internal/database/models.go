@@ -11,28 +11,151 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	KeyHash    string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type AuditLog struct {
+	ID            uuid.UUID
+	ActorID       uuid.UUID
+	Action        string
+	TargetUserID  uuid.NullUUID
+	TargetChirpID uuid.NullUUID
+	Details       string
+	CreatedAt     time.Time
+}
+
 type Chirp struct {
-	ID        uuid.UUID
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Body              string
+	UserID            uuid.UUID
+	BodyTsv           interface{}
+	InReplyTo         uuid.NullUUID
+	ThreadID          uuid.NullUUID
+	DeletedAt         sql.NullTime
+	Kind              string
+	ReferencedChirpID uuid.NullUUID
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MfaChallenge struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	TokenHash    string
+	TokenVersion int32
+	Attempts     int32
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	UsedAt       sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
 	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	ID            uuid.UUID
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
 
 type RefreshToken struct {
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ID         uuid.UUID
+	TokenHash  string
+	ReplacedBy uuid.NullUUID
+	UserAgent  string
+	IpAddress  string
+	DeviceName string
+	LastUsedAt time.Time
+	ClientID   uuid.NullUUID
+	Scope      sql.NullString
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         sql.NullBool
+	Handle              sql.NullString
+	DisplayName         string
+	Bio                 string
+	Location            string
+	DeletionRequestedAt sql.NullTime
+	TokenVersion        int32
+	TotpSecret          sql.NullString
+	TotpEnabledAt       sql.NullTime
+	TotpLastCounter     sql.NullInt64
+	EmailVerifiedAt     sql.NullTime
+	Role                string
+	SuspendedAt         sql.NullTime
+	SuspensionReason    string
+	DeletedAt           sql.NullTime
+}
+
+type UserToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
 }
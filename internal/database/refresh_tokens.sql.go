@@ -7,84 +7,346 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at) 
-VALUES ($1, $2, $3, $4, $5)
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+INSERT INTO refresh_tokens (
+	id, token_hash, created_at, updated_at, user_id, expires_at, family_id,
+	user_agent, ip_address, device_name, last_used_at, client_id, scope
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, id, token_hash, replaced_by, user_agent, ip_address, device_name, last_used_at, client_id, scope
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	ExpiresAt time.Time
+	ID         uuid.UUID
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	FamilyID   uuid.UUID
+	UserAgent  string
+	IpAddress  string
+	DeviceName string
+	LastUsedAt time.Time
+	ClientID   uuid.NullUUID
+	Scope      sql.NullString
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
 	row := q.db.QueryRowContext(ctx, createRefreshToken,
-		arg.Token,
+		arg.ID,
+		arg.TokenHash,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.DeviceName,
+		arg.LastUsedAt,
+		arg.ClientID,
+		arg.Scope,
 	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ID,
+		&i.TokenHash,
+		&i.ReplacedBy,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.LastUsedAt,
+		&i.ClientID,
+		&i.Scope,
 	)
 	return i, err
 }
 
 const findRefreshToken = `-- name: FindRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+SELECT created_at, updated_at, user_id, expires_at, revoked_at, family_id, id, token_hash, replaced_by, user_agent, ip_address, device_name, last_used_at, client_id, scope
 FROM refresh_tokens
-WHERE token = $1
-AND revoked_at IS NULL 
-AND expires_at > now()
+WHERE id = $1
 `
 
-func (q *Queries) FindRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, findRefreshToken, token)
+func (q *Queries) FindRefreshToken(ctx context.Context, id uuid.UUID) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, findRefreshToken, id)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ID,
+		&i.TokenHash,
+		&i.ReplacedBy,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.LastUsedAt,
+		&i.ClientID,
+		&i.Scope,
 	)
 	return i, err
 }
 
-const revokeRefreshToken = `-- name: RevokeRefreshToken :one
+const getRefreshTokensForUser = `-- name: GetRefreshTokensForUser :many
+SELECT created_at, updated_at, user_id, expires_at, revoked_at, family_id, id, token_hash, replaced_by, user_agent, ip_address, device_name, last_used_at, client_id, scope
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getRefreshTokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.FamilyID,
+			&i.ID,
+			&i.TokenHash,
+			&i.ReplacedBy,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.DeviceName,
+			&i.LastUsedAt,
+			&i.ClientID,
+			&i.Scope,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSessions = `-- name: ListSessions :many
+SELECT refresh_tokens.created_at, refresh_tokens.updated_at, refresh_tokens.user_id, refresh_tokens.expires_at, refresh_tokens.revoked_at, refresh_tokens.family_id, refresh_tokens.id, refresh_tokens.token_hash, refresh_tokens.replaced_by, refresh_tokens.user_agent, refresh_tokens.ip_address, refresh_tokens.device_name, refresh_tokens.last_used_at, refresh_tokens.client_id, refresh_tokens.scope,
+	(
+		SELECT min(family.created_at)
+		FROM refresh_tokens AS family
+		WHERE family.family_id = refresh_tokens.family_id
+	)::timestamp AS signed_in_at
+FROM refresh_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > now()
+ORDER BY last_used_at DESC
+`
+
+type ListSessionsRow struct {
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ID         uuid.UUID
+	TokenHash  string
+	ReplacedBy uuid.NullUUID
+	UserAgent  string
+	IpAddress  string
+	DeviceName string
+	LastUsedAt time.Time
+	ClientID   uuid.NullUUID
+	Scope      sql.NullString
+	SignedInAt time.Time
+}
+
+func (q *Queries) ListSessions(ctx context.Context, userID uuid.UUID) ([]ListSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsRow
+	for rows.Next() {
+		var i ListSessionsRow
+		if err := rows.Scan(
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.FamilyID,
+			&i.ID,
+			&i.TokenHash,
+			&i.ReplacedBy,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.DeviceName,
+			&i.LastUsedAt,
+			&i.ClientID,
+			&i.Scope,
+			&i.SignedInAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOtherSessions = `-- name: RevokeOtherSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE user_id = $1::uuid
+AND family_id <> $2::uuid
+AND revoked_at IS NULL
+`
+
+type RevokeOtherSessionsParams struct {
+	UserID   uuid.UUID
+	FamilyID uuid.UUID
+}
+
+func (q *Queries) RevokeOtherSessions(ctx context.Context, arg RevokeOtherSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeOtherSessions, arg.UserID, arg.FamilyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
 UPDATE refresh_tokens
-SET revoked_at = now()
-WHERE token = $1
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+SET revoked_at = now(),
+	updated_at = now()
+WHERE family_id = $1
+AND revoked_at IS NULL
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, revokeRefreshToken, token)
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const revokeRefreshTokensForUser = `-- name: RevokeRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE user_id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokensForUser, userID)
+	return err
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE family_id = $1::uuid
+AND user_id = $2::uuid
+AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSession, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now(),
+	replaced_by = $1::uuid
+WHERE id = $2::uuid
+AND revoked_at IS NULL
+AND expires_at > now()
+RETURNING created_at, updated_at, user_id, expires_at, revoked_at, family_id, id, token_hash, replaced_by, user_agent, ip_address, device_name, last_used_at, client_id, scope
+`
+
+type RotateRefreshTokenParams struct {
+	ReplacedBy uuid.UUID
+	ID         uuid.UUID
+}
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken, arg.ReplacedBy, arg.ID)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ID,
+		&i.TokenHash,
+		&i.ReplacedBy,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.DeviceName,
+		&i.LastUsedAt,
+		&i.ClientID,
+		&i.Scope,
 	)
 	return i, err
 }
+
+const sessionRevoked = `-- name: SessionRevoked :one
+-- A session has ended once one of its tokens was revoked without being
+-- replaced; rotating a token always records what replaced it.
+SELECT EXISTS (
+	SELECT 1
+	FROM refresh_tokens
+	WHERE family_id = $1
+	AND revoked_at IS NOT NULL
+	AND replaced_by IS NULL
+)
+`
+
+func (q *Queries) SessionRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, sessionRevoked, familyID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
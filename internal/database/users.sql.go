@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,7 +17,7 @@ const addChirpyRedByID = `-- name: AddChirpyRedByID :one
 UPDATE users
 SET is_chirpy_red = TRUE
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
 `
 
 func (q *Queries) AddChirpyRedByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -29,19 +30,83 @@ func (q *Queries) AddChirpyRedByID(ctx context.Context, id uuid.UUID) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const bumpTokenVersion = `-- name: BumpTokenVersion :one
+UPDATE users
+SET token_version = token_version + 1,
+	updated_at = now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+func (q *Queries) BumpTokenVersion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, bumpTokenVersion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+	updated_at = now()
+WHERE id = $1
+AND deletion_requested_at IS NOT NULL
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
 	gen_random_uuid(),
 	$1,
 	$2,
 	$3,
-	$4
-) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+	$4,
+	$5
+) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
 `
 
 type CreateUserParams struct {
@@ -49,6 +114,7 @@ type CreateUserParams struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -57,7 +123,65 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Handle,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
 	)
+	return i, err
+}
+
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL,
+	totp_enabled_at = NULL,
+	totp_last_counter = NULL,
+	updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableTOTP, id)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :one
+UPDATE users
+SET totp_enabled_at = now(),
+	totp_last_counter = $1::bigint,
+	updated_at = now()
+WHERE id = $2::uuid
+AND totp_secret IS NOT NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+type EnableTOTPParams struct {
+	TotpLastCounter int64
+	ID              uuid.UUID
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableTOTP, arg.TotpLastCounter, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -66,12 +190,53 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserAccess = `-- name: GetUserAccess :one
+SELECT email, email_verified_at, role, token_version, suspended_at
+FROM users
+WHERE id = $1
+`
+
+type GetUserAccessRow struct {
+	Email           string
+	EmailVerifiedAt sql.NullTime
+	Role            string
+	TokenVersion    int32
+	SuspendedAt     sql.NullTime
+}
+
+func (q *Queries) GetUserAccess(ctx context.Context, id uuid.UUID) (GetUserAccessRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserAccess, id)
+	var i GetUserAccessRow
+	err := row.Scan(
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.TokenVersion,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
 FROM users 
 WHERE email = $1
 `
@@ -86,26 +251,619 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const updateUserEmailAndPassword = `-- name: UpdateUserEmailAndPassword :one
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+FROM users
+WHERE lower(handle) = lower($1::text)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const listUsersAscending = `-- name: ListUsersAscending :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+FROM users
+WHERE ($1::text IS NULL
+	OR email ILIKE $1::text
+	OR handle ILIKE $1::text
+	OR display_name ILIKE $1::text)
+AND ($2::text IS NULL OR role = $2::text)
+AND ($3::boolean IS NULL OR (suspended_at IS NOT NULL) = $3::boolean)
+AND ($4::timestamp IS NULL
+	OR (created_at, id) > ($4::timestamp, $5::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListUsersAscendingParams struct {
+	Search          sql.NullString
+	Role            sql.NullString
+	Suspended       sql.NullBool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListUsersAscending(ctx context.Context, arg ListUsersAscendingParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersAscending,
+		arg.Search,
+		arg.Role,
+		arg.Suspended,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Location,
+			&i.DeletionRequestedAt,
+			&i.TokenVersion,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastCounter,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspensionReason,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersDescending = `-- name: ListUsersDescending :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+FROM users
+WHERE ($1::text IS NULL
+	OR email ILIKE $1::text
+	OR handle ILIKE $1::text
+	OR display_name ILIKE $1::text)
+AND ($2::text IS NULL OR role = $2::text)
+AND ($3::boolean IS NULL OR (suspended_at IS NOT NULL) = $3::boolean)
+AND ($4::timestamp IS NULL
+	OR (created_at, id) < ($4::timestamp, $5::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $6
+`
+
+type ListUsersDescendingParams struct {
+	Search          sql.NullString
+	Role            sql.NullString
+	Suspended       sql.NullBool
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListUsersDescending(ctx context.Context, arg ListUsersDescendingParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersDescending,
+		arg.Search,
+		arg.Role,
+		arg.Suspended,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Location,
+			&i.DeletionRequestedAt,
+			&i.TokenVersion,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastCounter,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspensionReason,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersPendingDeletion = `-- name: ListUsersPendingDeletion :many
+SELECT id
+FROM users
+WHERE deletion_requested_at < $1::timestamp
+`
+
+func (q *Queries) ListUsersPendingDeletion(ctx context.Context, requestedBefore time.Time) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersPendingDeletion, requestedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUserPassword = `-- name: LockUserPassword :exec
 UPDATE users
-SET email = $2,
-	hashed_password = $3
+SET hashed_password = '',
+	updated_at = now()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
 `
 
-type UpdateUserEmailAndPasswordParams struct {
+func (q *Queries) LockUserPassword(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUserPassword, id)
+	return err
+}
+
+const purgeUser = `-- name: PurgeUser :exec
+WITH deleted_refresh_tokens AS (
+	DELETE FROM refresh_tokens WHERE user_id = $1::uuid
+), deleted_api_keys AS (
+	DELETE FROM api_keys WHERE user_id = $1::uuid
+), deleted_authorization_codes AS (
+	DELETE FROM oauth_authorization_codes WHERE user_id = $1::uuid
+), deleted_user_tokens AS (
+	DELETE FROM user_tokens WHERE user_id = $1::uuid
+), deleted_recovery_codes AS (
+	DELETE FROM mfa_recovery_codes WHERE user_id = $1::uuid
+), deleted_mfa_challenges AS (
+	DELETE FROM mfa_challenges WHERE user_id = $1::uuid
+), deleted_likes AS (
+	DELETE FROM chirp_likes WHERE user_id = $1::uuid
+), deleted_follows AS (
+	DELETE FROM follows WHERE follower_id = $1::uuid OR followee_id = $1::uuid
+)
+UPDATE users
+SET email = 'deleted-' || id,
+	hashed_password = '',
+	is_chirpy_red = FALSE,
+	handle = NULL,
+	display_name = '',
+	bio = '',
+	location = '',
+	deletion_requested_at = NULL,
+	token_version = token_version + 1,
+	totp_secret = NULL,
+	totp_enabled_at = NULL,
+	totp_last_counter = NULL,
+	email_verified_at = NULL,
+	suspended_at = NULL,
+	suspension_reason = '',
+	deleted_at = now(),
+	updated_at = now()
+WHERE id = $1::uuid
+`
+
+func (q *Queries) PurgeUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, purgeUser, id)
+	return err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1::text
+WHERE id = $2::uuid
+AND hashed_password = $3::text
+`
+
+type RehashUserPasswordParams struct {
+	NewHashedPassword string
+	ID                uuid.UUID
+	OldHashedPassword string
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHashedPassword, arg.ID, arg.OldHashedPassword)
+	return err
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = now(),
+	updated_at = now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, requestUserDeletion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $1::text,
+	totp_enabled_at = NULL,
+	totp_last_counter = NULL,
+	updated_at = now()
+WHERE id = $2::uuid
+`
+
+type SetTOTPSecretParams struct {
+	TotpSecret string
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setTOTPSecret, arg.TotpSecret, arg.ID)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $1::text,
+	updated_at = now()
+WHERE id = $2::uuid
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = COALESCE(suspended_at, now()),
+	suspension_reason = $1::text,
+	updated_at = now()
+WHERE id = $2::uuid
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+type SuspendUserParams struct {
+	SuspensionReason string
+	ID               uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.SuspensionReason, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+	suspension_reason = '',
+	updated_at = now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET email = COALESCE($1::text, email),
+	email_verified_at = CASE
+		WHEN $1::text <> email THEN NULL
+		ELSE email_verified_at
+	END,
+	hashed_password = COALESCE($2::text, hashed_password),
+	handle = COALESCE($3::text, handle),
+	display_name = COALESCE($4::text, display_name),
+	bio = COALESCE($5::text, bio),
+	location = COALESCE($6::text, location),
+	updated_at = now()
+WHERE id = $7
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+type UpdateUserParams struct {
+	Email          sql.NullString
+	HashedPassword sql.NullString
+	Handle         sql.NullString
+	DisplayName    sql.NullString
+	Bio            sql.NullString
+	Location       sql.NullString
 	ID             uuid.UUID
-	Email          string
-	HashedPassword string
 }
 
-func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUserEmailAndPasswordParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserEmailAndPassword, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const useTOTPCounter = `-- name: UseTOTPCounter :execrows
+UPDATE users
+SET totp_last_counter = $1::bigint
+WHERE id = $2::uuid
+AND (totp_last_counter IS NULL OR totp_last_counter < $1::bigint)
+`
+
+type UseTOTPCounterParams struct {
+	TotpLastCounter int64
+	ID              uuid.UUID
+}
+
+func (q *Queries) UseTOTPCounter(ctx context.Context, arg UseTOTPCounterParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPCounter, arg.TotpLastCounter, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = now(),
+	updated_at = now()
+WHERE id = $1::uuid
+AND email = $2::text
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, location, deletion_requested_at, token_version, totp_secret, totp_enabled_at, totp_last_counter, email_verified_at, role, suspended_at, suspension_reason, deleted_at
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.ID, arg.Email)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -114,6 +872,20 @@ func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUser
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.DeletionRequestedAt,
+		&i.TokenVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastCounter,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,102 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpTimeout bounds a whole send, so a stalled server can't hold up the
+// request waiting on it.
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer sends through an SMTP server, authenticating with PLAIN auth
+// when a username is set. The connection is upgraded to TLS when the
+// server offers STARTTLS. From may have a display name, which only goes in
+// the From header.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := formatMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+	// The envelope sender must be a bare address.
+	sender, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.Host, m.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// net/smtp knows nothing of contexts, so the context is enforced
+	// through deadlines on the connection.
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(sender.Address)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/database"
+)
+
+// DatabaseStore keeps records in the login_attempts table so every server
+// instance shares them.
+type DatabaseStore struct {
+	db *database.Queries
+}
+
+func NewDatabaseStore(db *database.Queries) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+func (s *DatabaseStore) Get(ctx context.Context, key string) (Record, error) {
+	dbAttempts, err := s.db.GetLoginAttempts(ctx, key)
+	if err == sql.ErrNoRows {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return dbLoginAttemptToRecord(dbAttempts), nil
+}
+
+func (s *DatabaseStore) AddFailure(ctx context.Context, key string, failedAt, windowStart time.Time) (Record, error) {
+	dbAttempts, err := s.db.AddLoginFailure(ctx, database.AddLoginFailureParams{
+		Key:         key,
+		FailedAt:    failedAt,
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return dbLoginAttemptToRecord(dbAttempts), nil
+}
+
+func (s *DatabaseStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.LockLoginAttempts(ctx, database.LockLoginAttemptsParams{
+		LockedUntil: until,
+		Key:         key,
+	})
+}
+
+func (s *DatabaseStore) Reset(ctx context.Context, key string) error {
+	return s.db.ResetLoginAttempts(ctx, key)
+}
+
+func (s *DatabaseStore) Prune(ctx context.Context, windowStart time.Time) error {
+	_, err := s.db.DeleteStaleLoginAttempts(ctx, windowStart)
+	return err
+}
+
+func dbLoginAttemptToRecord(dbAttempts database.LoginAttempt) Record {
+	return Record{
+		Failures:      int(dbAttempts.Failures),
+		LastFailureAt: dbAttempts.LastFailureAt,
+		LockedUntil:   dbAttempts.LockedUntil.Time,
+	}
+}
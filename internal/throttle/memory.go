@@ -0,0 +1,70 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process. It is only correct when a single
+// server instance handles every login.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) AddFailure(ctx context.Context, key string, failedAt, windowStart time.Time) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	if record.LastFailureAt.Before(windowStart) {
+		record.Failures = 0
+	}
+	record.Failures++
+	record.LastFailureAt = failedAt
+	s.records[key] = record
+	return record, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	if until.After(record.LockedUntil) {
+		record.LockedUntil = until
+	}
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, windowStart time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, record := range s.records {
+		if record.LastFailureAt.Before(windowStart) && record.LockedUntil.Before(now) {
+			delete(s.records, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Package throttle tracks failed attempts per key and locks keys out with
+// exponential backoff once they fail too often.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// A Record is the failure history of one key.
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// A Store keeps records where every server instance can see them.
+// AddFailure must be atomic so concurrent failures are all counted.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	AddFailure(ctx context.Context, key string, failedAt, windowStart time.Time) (Record, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	Prune(ctx context.Context, windowStart time.Time) error
+}
+
+// A Policy allows FreeAttempts failures, then locks the key for BaseDelay,
+// doubling with every further failure up to MaxDelay. Failures are
+// forgotten once none has happened for Window.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Window       time.Duration
+}
+
+func (p Policy) lockout(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// A Limiter applies a Policy to keys in one namespace of a Store.
+type Limiter struct {
+	store  Store
+	prefix string
+	policy Policy
+}
+
+func NewLimiter(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{store: store, prefix: prefix, policy: policy}
+}
+
+// Check returns how long key has to wait before its next attempt, or zero
+// if it may try now.
+func (l *Limiter) Check(ctx context.Context, key string) (time.Duration, error) {
+	record, err := l.store.Get(ctx, l.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return retryAfter(record.LockedUntil), nil
+}
+
+// Fail records a failed attempt and returns the lockout it caused, if any.
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	record, err := l.store.AddFailure(ctx, l.prefix+key, now, now.Add(-l.policy.Window))
+	if err != nil {
+		return 0, err
+	}
+
+	lockout := l.policy.lockout(record.Failures)
+	if lockout == 0 {
+		return 0, nil
+	}
+
+	err = l.store.Lock(ctx, l.prefix+key, now.Add(lockout))
+	if err != nil {
+		return 0, err
+	}
+	return lockout, nil
+}
+
+// Reset forgets every failure for key, lifting any lockout.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, l.prefix+key)
+}
+
+// Prune drops records that are no longer locked and have fallen outside
+// the window.
+func (l *Limiter) Prune(ctx context.Context) error {
+	return l.store.Prune(ctx, time.Now().Add(-l.policy.Window))
+}
+
+func retryAfter(lockedUntil time.Time) time.Duration {
+	wait := time.Until(lockedUntil)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
@@ -0,0 +1,173 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Minute,
+	MaxDelay:     10 * time.Minute,
+	Window:       time.Hour,
+}
+
+func TestPolicyLockout(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 5, want: 4 * time.Minute},
+		{failures: 6, want: 8 * time.Minute},
+		{failures: 7, want: 10 * time.Minute},
+		{failures: 1000, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := testPolicy.lockout(tt.failures); got != tt.want {
+			t.Errorf("lockout(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterLocksOut(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(NewMemoryStore(), "account:", testPolicy)
+
+	for i := 1; i <= 4; i++ {
+		wait, err := limiter.Check(ctx, "a@example.com")
+		if err != nil {
+			t.Fatalf("Check returned error: %v", err)
+		}
+		if i <= testPolicy.FreeAttempts && wait != 0 {
+			t.Fatalf("attempt %d: Check = %s before the free attempts ran out", i, wait)
+		}
+		if i > testPolicy.FreeAttempts && wait == 0 {
+			t.Fatalf("attempt %d: Check = 0 after the free attempts ran out", i)
+		}
+
+		lockout, err := limiter.Fail(ctx, "a@example.com")
+		if err != nil {
+			t.Fatalf("Fail returned error: %v", err)
+		}
+		if want := testPolicy.lockout(i); lockout != want {
+			t.Errorf("failure %d: Fail = %s, want %s", i, lockout, want)
+		}
+	}
+
+	wait, err := limiter.Check(ctx, "a@example.com")
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if wait <= time.Minute || wait > 2*time.Minute {
+		t.Errorf("Check after 4 failures = %s, want just under 2m", wait)
+	}
+
+	wait, err = limiter.Check(ctx, "b@example.com")
+	if err != nil || wait != 0 {
+		t.Errorf("Check of another key = %s, %v, want 0", wait, err)
+	}
+
+	err = limiter.Reset(ctx, "a@example.com")
+	if err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+	wait, err = limiter.Check(ctx, "a@example.com")
+	if err != nil || wait != 0 {
+		t.Errorf("Check after Reset = %s, %v, want 0", wait, err)
+	}
+	lockout, err := limiter.Fail(ctx, "a@example.com")
+	if err != nil || lockout != 0 {
+		t.Errorf("Fail after Reset = %s, %v, want the count to start over", lockout, err)
+	}
+}
+
+func TestLimiterPrefixesKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	accounts := NewLimiter(store, "account:", testPolicy)
+	ips := NewLimiter(store, "ip:", testPolicy)
+
+	for i := 0; i < testPolicy.FreeAttempts; i++ {
+		_, err := accounts.Fail(ctx, "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Fail returned error: %v", err)
+		}
+	}
+
+	wait, err := accounts.Check(ctx, "127.0.0.1")
+	if err != nil || wait == 0 {
+		t.Errorf("accounts Check = %s, %v, want a lockout", wait, err)
+	}
+	wait, err = ips.Check(ctx, "127.0.0.1")
+	if err != nil || wait != 0 {
+		t.Errorf("ips Check = %s, %v, want no lockout from another limiter", wait, err)
+	}
+}
+
+func TestLimiterForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, "account:", testPolicy)
+
+	// Failures from before the window don't count towards a lockout.
+	longAgo := time.Now().Add(-2 * testPolicy.Window)
+	for i := 0; i < testPolicy.FreeAttempts-1; i++ {
+		_, err := store.AddFailure(ctx, "account:a@example.com", longAgo, longAgo.Add(-testPolicy.Window))
+		if err != nil {
+			t.Fatalf("AddFailure returned error: %v", err)
+		}
+	}
+
+	lockout, err := limiter.Fail(ctx, "a@example.com")
+	if err != nil || lockout != 0 {
+		t.Errorf("Fail after the window = %s, %v, want the count to start over", lockout, err)
+	}
+}
+
+func TestLimiterPrune(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, "account:", testPolicy)
+
+	longAgo := time.Now().Add(-2 * testPolicy.Window)
+	for _, key := range []string{"account:stale", "account:locked"} {
+		_, err := store.AddFailure(ctx, key, longAgo, longAgo.Add(-testPolicy.Window))
+		if err != nil {
+			t.Fatalf("AddFailure returned error: %v", err)
+		}
+	}
+	err := store.Lock(ctx, "account:locked", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Lock returned error: %v", err)
+	}
+	_, err = limiter.Fail(ctx, "recent")
+	if err != nil {
+		t.Fatalf("Fail returned error: %v", err)
+	}
+
+	err = limiter.Prune(ctx)
+	if err != nil {
+		t.Fatalf("Prune returned error: %v", err)
+	}
+
+	for key, wantKept := range map[string]bool{
+		"account:stale":  false,
+		"account:locked": true,
+		"account:recent": true,
+	} {
+		record, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if kept := record.Failures > 0; kept != wantKept {
+			t.Errorf("after Prune, %s kept = %v, want %v", key, kept, wantKept)
+		}
+	}
+}
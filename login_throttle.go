@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/throttle"
+	"github.com/google/uuid"
+)
+
+var (
+	accountLoginPolicy = throttle.Policy{
+		FreeAttempts: 5,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     time.Hour,
+		Window:       24 * time.Hour,
+	}
+	ipLoginPolicy = throttle.Policy{
+		FreeAttempts: 20,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     time.Hour,
+		Window:       24 * time.Hour,
+	}
+
+	// Emailed links are limited by how many are asked for rather than by
+	// failures, so nobody can flood an inbox or the mail server.
+	accountEmailLinkPolicy = throttle.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    5 * time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+	ipEmailLinkPolicy = throttle.Policy{
+		FreeAttempts: 20,
+		BaseDelay:    5 * time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+// loadLoginThrottle sets up failed-login tracking per account and per IP,
+// along with the limits on emailed links.
+// LOGIN_THROTTLE_STORE=memory keeps it in process for a single node; the
+// default shares it between instances through the database.
+func (cfg *apiConfig) loadLoginThrottle() error {
+	var store throttle.Store
+	switch os.Getenv("LOGIN_THROTTLE_STORE") {
+	case "", "database":
+		store = throttle.NewDatabaseStore(cfg.db)
+	case "memory":
+		store = throttle.NewMemoryStore()
+	default:
+		return fmt.Errorf("LOGIN_THROTTLE_STORE must be database or memory")
+	}
+
+	cfg.accountLoginLimiter = throttle.NewLimiter(store, "account:", accountLoginPolicy)
+	cfg.ipLoginLimiter = throttle.NewLimiter(store, "ip:", ipLoginPolicy)
+	cfg.accountEmailLinkLimiter = throttle.NewLimiter(store, "email-link:account:", accountEmailLinkPolicy)
+	cfg.ipEmailLinkLimiter = throttle.NewLimiter(store, "email-link:ip:", ipEmailLinkPolicy)
+	return nil
+}
+
+// checkLoginThrottle responds with a 429 if the account or the client's IP
+// is locked out, and reports whether the login may go ahead.
+func (cfg *apiConfig) checkLoginThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	wait, err := cfg.loginThrottleWait(r, email)
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return false
+	}
+
+	if wait > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, 429, "too many failed login attempts, try again later")
+		return false
+	}
+	return true
+}
+
+// loginThrottleWait is how long until the account and the client's IP may
+// try to log in again; zero if neither is locked out.
+func (cfg *apiConfig) loginThrottleWait(r *http.Request, email string) (time.Duration, error) {
+	accountWait, err := cfg.accountLoginLimiter.Check(r.Context(), loginAccountKey(email))
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := cfg.ipLoginLimiter.Check(r.Context(), clientIP(r))
+	if err != nil {
+		return 0, err
+	}
+	return max(accountWait, ipWait), nil
+}
+
+// recordLoginFailure counts a failed login against both the account and
+// the IP. Unknown emails are counted too, so lockouts don't reveal which
+// accounts exist.
+func (cfg *apiConfig) recordLoginFailure(r *http.Request, email string) {
+	_, err := cfg.accountLoginLimiter.Fail(r.Context(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Error recording failed login: %s", err)
+	}
+	_, err = cfg.ipLoginLimiter.Fail(r.Context(), clientIP(r))
+	if err != nil {
+		log.Printf("Error recording failed login: %s", err)
+	}
+}
+
+// recordLoginSuccess clears the account's failures. The IP's are kept, or
+// an attacker could reset them by logging into an account of their own.
+func (cfg *apiConfig) recordLoginSuccess(r *http.Request, email string) {
+	err := cfg.accountLoginLimiter.Reset(r.Context(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Error resetting failed logins: %s", err)
+	}
+}
+
+// checkEmailLinkThrottle responds with a 429 if the account or the client's
+// IP has asked for too many emailed links, and otherwise counts this one.
+// It reports whether the email may be sent. Unknown emails are counted
+// too, so the limit doesn't reveal which accounts exist.
+func (cfg *apiConfig) checkEmailLinkThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	accountWait, err := cfg.accountEmailLinkLimiter.Check(r.Context(), loginAccountKey(email))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return false
+	}
+	ipWait, err := cfg.ipEmailLinkLimiter.Check(r.Context(), clientIP(r))
+	if err != nil {
+		respondWithError(w, 500, "something went wrong")
+		return false
+	}
+
+	if wait := max(accountWait, ipWait); wait > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, 429, "too many emails requested, try again later")
+		return false
+	}
+
+	_, err = cfg.accountEmailLinkLimiter.Fail(r.Context(), loginAccountKey(email))
+	if err != nil {
+		log.Printf("Error recording emailed link: %s", err)
+	}
+	_, err = cfg.ipEmailLinkLimiter.Fail(r.Context(), clientIP(r))
+	if err != nil {
+		log.Printf("Error recording emailed link: %s", err)
+	}
+	return true
+}
+
+func (cfg *apiConfig) pruneLoginThrottle(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, limiter := range []*throttle.Limiter{
+			cfg.accountLoginLimiter,
+			cfg.ipLoginLimiter,
+			cfg.accountEmailLinkLimiter,
+			cfg.ipEmailLinkLimiter,
+		} {
+			err := limiter.Prune(ctx)
+			if err != nil {
+				log.Printf("Error pruning throttle records: %s", err)
+			}
+		}
+	}
+}
+
+// handleUnlockLogin lets an admin lift a lockout on an account, an IP, or
+// both. Like the rest of the admin API, it is recorded in the audit log.
+func (cfg *apiConfig) handleUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	type requestUnlock struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+
+	defer r.Body.Close()
+	var reqUnlock requestUnlock
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqUnlock)
+	if err != nil {
+		respondWithError(w, 400, "malformed request")
+		return
+	}
+
+	if reqUnlock.Email == "" && reqUnlock.IP == "" {
+		respondWithError(w, 400, "email or ip required")
+		return
+	}
+
+	if reqUnlock.Email != "" {
+		err = cfg.accountLoginLimiter.Reset(r.Context(), loginAccountKey(reqUnlock.Email))
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+	}
+	if reqUnlock.IP != "" {
+		err = cfg.ipLoginLimiter.Reset(r.Context(), strings.TrimSpace(reqUnlock.IP))
+		if err != nil {
+			respondWithError(w, 500, "something went wrong")
+			return
+		}
+	}
+
+	// Unknown emails are locked out too, so the email may not belong to an
+	// account.
+	var targetUserID uuid.NullUUID
+	var details []string
+	if reqUnlock.Email != "" {
+		dbUser, err := cfg.db.GetUserByEmail(r.Context(), reqUnlock.Email)
+		if err == nil {
+			targetUserID = userTarget(dbUser.ID)
+		}
+		details = append(details, "email="+loginAccountKey(reqUnlock.Email))
+	}
+	if reqUnlock.IP != "" {
+		details = append(details, "ip="+strings.TrimSpace(reqUnlock.IP))
+	}
+	cfg.audit(r, auditLoginUnlock, targetUserID, uuid.NullUUID{}, strings.Join(details, " "))
+
+	respondWithJSON(w, 204, nil)
+}
+
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
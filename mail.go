@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/KidMuon/chirpy/internal/mailer"
+)
+
+const defaultAppBaseURL = "http://localhost:8080"
+
+// loadMailer picks how emails go out from MAILER: "smtp" sends through
+// SMTP_HOST, "memory" keeps them in process, and the default "file" writes
+// them to MAIL_DIR for development.
+func loadMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+	_, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+
+	switch os.Getenv("MAILER") {
+	case "", "file":
+		// Not under the working directory, which /app/ serves publicly.
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "chirpy-mail")
+		}
+		return &mailer.FileMailer{Dir: dir, From: from}, nil
+	case "memory":
+		return &mailer.MemoryMailer{}, nil
+	case "smtp":
+		smtpMailer := &mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		if smtpMailer.Host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required when MAILER=smtp")
+		}
+		if smtpMailer.Port == "" {
+			smtpMailer.Port = "587"
+		}
+		return smtpMailer, nil
+	default:
+		return nil, fmt.Errorf("MAILER must be smtp, file or memory")
+	}
+}
+
+// appURL builds a link into the web app for emails, with the token in the
+// query string.
+func (cfg *apiConfig) appURL(path, token string) string {
+	return cfg.appBaseURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// sendMail logs rather than returns failures: a user waiting on an email
+// can ask for it again, but the request that triggered it has succeeded.
+func (cfg *apiConfig) sendMail(ctx context.Context, msg mailer.Message) {
+	err := cfg.mailer.Send(ctx, msg)
+	if err != nil {
+		log.Printf("Error sending %q email: %s", msg.Subject, err)
+	}
+}
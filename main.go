@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
 	"sync/atomic"
+	"time"
 
+	"github.com/KidMuon/chirpy/internal/auth"
 	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/KidMuon/chirpy/internal/mailer"
+	"github.com/KidMuon/chirpy/internal/throttle"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -16,8 +21,21 @@ type apiConfig struct {
 	fileserverhits atomic.Int32
 	db             *database.Queries
 	platform       string
-	tokenSecret    string
+	tokens         auth.TokenConfig
+	adminEmails    map[string]bool
+	mfaKey         []byte
+	mailer         mailer.Mailer
+	appBaseURL     string
 	polkaKey       string
+	passwordHasher auth.Hasher
+	passwordPolicy auth.PasswordPolicy
+
+	deletionGracePeriod time.Duration
+	accountLoginLimiter *throttle.Limiter
+	ipLoginLimiter      *throttle.Limiter
+
+	accountEmailLinkLimiter *throttle.Limiter
+	ipEmailLinkLimiter      *throttle.Limiter
 }
 
 func main() {
@@ -33,8 +51,47 @@ func main() {
 	var cfg apiConfig
 	cfg.db = dbQueries
 	cfg.platform = os.Getenv("PLATFORM")
-	cfg.tokenSecret = os.Getenv("TOKEN_SECRET")
+	cfg.tokens, err = loadTokenConfig()
+	if err != nil {
+		log.Fatalf("Cannot load token configuration: %s", err)
+	}
+	cfg.adminEmails = loadAdminEmails()
+	cfg.passwordHasher, err = loadPasswordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.passwordPolicy, err = loadPasswordPolicy(cfg.passwordHasher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.mfaKey, err = loadMFAKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.mailer, err = loadMailer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.appBaseURL = os.Getenv("APP_BASE_URL")
+	if cfg.appBaseURL == "" {
+		cfg.appBaseURL = defaultAppBaseURL
+	}
 	cfg.polkaKey = os.Getenv("POLKA_KEY")
+	cfg.deletionGracePeriod = defaultDeletionGracePeriod
+	if gracePeriod := os.Getenv("DELETION_GRACE_PERIOD"); gracePeriod != "" {
+		cfg.deletionGracePeriod, err = time.ParseDuration(gracePeriod)
+		if err != nil {
+			log.Fatal("Invalid DELETION_GRACE_PERIOD")
+		}
+	}
+
+	err = cfg.loadLoginThrottle()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go cfg.purgeDeletedAccounts(context.Background(), time.Hour)
+	go cfg.pruneLoginThrottle(context.Background(), time.Hour)
 
 	mux := http.NewServeMux()
 	appPathHandler := http.FileServer(http.Dir("."))
@@ -42,21 +99,71 @@ func main() {
 
 	mux.HandleFunc("GET /admin/metrics", cfg.handleServeMetric)
 	mux.HandleFunc("POST /admin/reset", cfg.handleResetMetric)
+	mux.HandleFunc("POST /admin/api/login-throttle/unlock", cfg.middlewareRole(cfg.handleUnlockLogin, auth.RoleAdmin))
+	mux.HandleFunc("POST /admin/api/oauth/clients", cfg.middlewareRole(cfg.handleCreateOAuthClient, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/api/oauth/clients", cfg.middlewareRole(cfg.handleGetOAuthClients, auth.RoleAdmin))
+	mux.HandleFunc("DELETE /admin/api/oauth/clients/{clientID}", cfg.middlewareRole(cfg.handleDeleteOAuthClient, auth.RoleAdmin))
+	mux.HandleFunc("GET /admin/api/users", cfg.middlewareRole(cfg.handleAdminGetUsers, auth.RoleModerator))
+	mux.HandleFunc("GET /admin/api/users/{userID}", cfg.middlewareRole(cfg.handleAdminGetUser, auth.RoleModerator))
+	mux.HandleFunc("POST /admin/api/users/{userID}/suspension", cfg.middlewareRole(cfg.handleSuspendUser, auth.RoleModerator))
+	mux.HandleFunc("DELETE /admin/api/users/{userID}/suspension", cfg.middlewareRole(cfg.handleUnsuspendUser, auth.RoleModerator))
+	mux.HandleFunc("POST /admin/api/users/{userID}/password-reset", cfg.middlewareRole(cfg.handleForcePasswordReset, auth.RoleAdmin))
+	mux.HandleFunc("PUT /admin/api/users/{userID}/role", cfg.middlewareRole(cfg.handleSetUserRole, auth.RoleAdmin))
+	mux.HandleFunc("POST /admin/api/users/{userID}/chirpy-red", cfg.middlewareRole(cfg.handleGrantChirpyRed, auth.RoleAdmin))
+	mux.HandleFunc("DELETE /admin/api/chirps/{chirpID}", cfg.middlewareRole(cfg.handleAdminDeleteChirp, auth.RoleModerator))
+	mux.HandleFunc("GET /admin/api/audit-log", cfg.middlewareRole(cfg.handleGetAuditLog, auth.RoleAdmin))
 
 	mux.HandleFunc("GET /api/healthz", handleHealthz)
 
-	mux.HandleFunc("POST /api/users", cfg.handleCreateUser)
-	mux.HandleFunc("PUT /api/users", cfg.handleUpdateUser)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.handleGetJWKS)
 
-	mux.HandleFunc("POST /api/chirps", cfg.handleCreateChirp)
-	mux.HandleFunc("GET /api/chirps", cfg.handleGetAllChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handleGetChirpByID)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handleDeleteChirpByID)
+	mux.HandleFunc("POST /api/users", cfg.handleCreateUser)
+	mux.HandleFunc("PUT /api/users", cfg.middlewareAuth(cfg.handleUpdateUser, auth.ScopeUsersWrite))
+	mux.HandleFunc("PATCH /api/users", cfg.middlewareAuth(cfg.handleUpdateUser, auth.ScopeUsersWrite))
+	mux.HandleFunc("DELETE /api/users/me", cfg.middlewareAuth(cfg.handleDeleteAccount, auth.ScopeUsersWrite))
+	mux.HandleFunc("GET /api/users/me/export", cfg.middlewareAuth(cfg.handleExportAccount, auth.ScopeUsersRead))
+	mux.HandleFunc("POST /api/users/me/email/verification", cfg.middlewareAuth(cfg.handleResendVerificationEmail, auth.ScopeUsersWrite))
+	mux.HandleFunc("POST /api/users/me/mfa/totp", cfg.middlewareAuth(cfg.handleEnrollTOTP, auth.ScopeUsersWrite))
+	mux.HandleFunc("POST /api/users/me/mfa/totp/verify", cfg.middlewareAuth(cfg.handleVerifyTOTP, auth.ScopeUsersWrite))
+	mux.HandleFunc("DELETE /api/users/me/mfa/totp", cfg.middlewareAuth(cfg.handleDisableTOTP, auth.ScopeUsersWrite))
+	mux.HandleFunc("POST /api/users/me/api-keys", cfg.middlewareAuth(cfg.handleCreateAPIKey, auth.ScopeUsersWrite))
+	mux.HandleFunc("GET /api/users/me/api-keys", cfg.middlewareAuth(cfg.handleGetAPIKeys, auth.ScopeUsersRead))
+	mux.HandleFunc("DELETE /api/users/me/api-keys/{keyID}", cfg.middlewareAuth(cfg.handleDeleteAPIKey, auth.ScopeUsersWrite))
+	mux.HandleFunc("GET /api/users/{handleOrID}", cfg.handleGetUserProfile)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.middlewareAuth(cfg.middlewareVerifiedEmail(cfg.handleFollowUser), auth.ScopeUsersWrite))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.middlewareAuth(cfg.handleUnfollowUser, auth.ScopeUsersWrite))
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.handleGetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.handleGetFollowing)
+
+	mux.HandleFunc("GET /api/timeline", cfg.middlewareAuth(cfg.handleGetTimeline, auth.ScopeUsersRead))
+
+	mux.HandleFunc("POST /api/chirps", cfg.middlewareAuth(cfg.middlewareVerifiedEmail(cfg.handleCreateChirp), auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/chirps", cfg.middlewareOptionalAuth(cfg.handleGetAllChirps))
+	mux.HandleFunc("GET /api/chirps/search", cfg.middlewareOptionalAuth(cfg.handleSearchChirps))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.middlewareOptionalAuth(cfg.handleGetChirpByID))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.middlewareAuth(cfg.handleDeleteChirpByID, auth.ScopeChirpsWrite))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/replies", cfg.middlewareOptionalAuth(cfg.handleGetChirpReplies))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.middlewareOptionalAuth(cfg.handleGetChirpThread))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/likes", cfg.handleGetChirpLikes)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.middlewareAuth(cfg.middlewareVerifiedEmail(cfg.handleLikeChirp), auth.ScopeChirpsWrite))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.middlewareAuth(cfg.handleUnlikeChirp, auth.ScopeChirpsWrite))
 
 	mux.HandleFunc("POST /api/login", cfg.handleLoginUser)
+	mux.HandleFunc("POST /api/login/mfa", cfg.handleLoginMFA)
+	mux.HandleFunc("POST /api/password/forgot", cfg.handleForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", cfg.handleResetPassword)
+	mux.HandleFunc("POST /api/email/verify", cfg.handleVerifyEmail)
 	mux.HandleFunc("POST /api/refresh", cfg.handleRefresh)
 	mux.HandleFunc("POST /api/revoke", cfg.handleRevoke)
 
+	mux.HandleFunc("GET /oauth/authorize", cfg.handleAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.handleAuthorizeConsent)
+	mux.HandleFunc("POST /oauth/token", cfg.handleOAuthToken)
+
+	mux.HandleFunc("GET /api/sessions", cfg.middlewareAuth(cfg.handleGetSessions, auth.ScopeUsersRead))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.middlewareAuth(cfg.handleDeleteSession, auth.ScopeUsersWrite))
+	mux.HandleFunc("POST /api/sessions/revoke-others", cfg.handleRevokeOtherSessions)
+
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.handleWebhooks)
 
 	server := http.Server{
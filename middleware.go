@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const accessTokenContextKey contextKey = "accessToken"
+
+// middlewareAuth only lets requests through with a valid access token that
+// carries every one of the given scopes. The token is put on the request
+// context for the handler.
+func (cfg *apiConfig) middlewareAuth(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			respondWithAuthError(w, "", "no authentication found")
+			return
+		}
+
+		accessToken, ok := cfg.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		if !accessToken.HasScopes(scopes...) {
+			scope := strings.Join(scopes, " ")
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope="%s"`, scope))
+			respondWithError(w, 403, "token is missing scope "+scope)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), accessTokenContextKey, accessToken)))
+	}
+}
+
+// middlewareRole only lets through users with at least the given role.
+// The admin scope is required as well, so tokens issued to OAuth clients,
+// which never get it, can't reach admin routes whoever authorized them.
+func (cfg *apiConfig) middlewareRole(next http.HandlerFunc, minRole string) http.HandlerFunc {
+	return cfg.middlewareAuth(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, _ := accessTokenFromContext(r.Context())
+		if !auth.HasRole(accessToken.Role, minRole) {
+			respondWithError(w, 403, "requires the "+minRole+" role")
+			return
+		}
+		next(w, r)
+	}, auth.ScopeUsersAdmin)
+}
+
+// middlewareOptionalAuth lets anonymous requests through, so public routes
+// can still tailor their response to a logged-in viewer. A token that is
+// sent but invalid is rejected rather than ignored.
+func (cfg *apiConfig) middlewareOptionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+
+		accessToken, ok := cfg.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), accessTokenContextKey, accessToken)))
+	}
+}
+
+// authenticate accepts either a bearer access token or a user API key.
+func (cfg *apiConfig) authenticate(w http.ResponseWriter, r *http.Request) (auth.AccessToken, bool) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		accessToken, err := cfg.validateAPIKey(r.Context(), apiKey)
+		if err != nil {
+			respondWithAuthError(w, "invalid_token", err.Error())
+			return auth.AccessToken{}, false
+		}
+		return accessToken, true
+	}
+
+	authToken, err := auth.GetBearerToken(r.Header)
+	if err != nil || authToken == "" {
+		respondWithAuthError(w, "invalid_request", "no authentication found")
+		return auth.AccessToken{}, false
+	}
+
+	accessToken, err := cfg.validateAccessToken(r.Context(), authToken)
+	if err != nil {
+		respondWithAuthError(w, "invalid_token", err.Error())
+		return auth.AccessToken{}, false
+	}
+
+	return accessToken, true
+}
+
+// respondWithAuthError sends a 401 with the WWW-Authenticate challenge from
+// RFC 6750. errorCode is left out when no credentials were sent at all.
+func respondWithAuthError(w http.ResponseWriter, errorCode, msg string) {
+	challenge := `Bearer realm="chirpy"`
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error="%s"`, errorCode)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, 401, msg)
+}
+
+func accessTokenFromContext(ctx context.Context) (auth.AccessToken, bool) {
+	accessToken, ok := ctx.Value(accessTokenContextKey).(auth.AccessToken)
+	return accessToken, ok
+}
+
+// userIDFromContext is the authenticated user on routes behind
+// middlewareAuth.
+func userIDFromContext(ctx context.Context) uuid.UUID {
+	accessToken, _ := accessTokenFromContext(ctx)
+	return accessToken.UserID
+}
+
+// viewerIDFromContext is the caller on routes behind middlewareOptionalAuth,
+// or null for anonymous requests.
+func viewerIDFromContext(ctx context.Context) uuid.NullUUID {
+	accessToken, ok := accessTokenFromContext(ctx)
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: accessToken.UserID, Valid: true}
+}
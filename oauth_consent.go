@@ -0,0 +1,125 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+)
+
+// scopeDescriptions is how each scope is explained on the consent screen.
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsWrite: "Post and delete chirps and likes as you",
+	auth.ScopeUsersRead:   "See your timeline, sessions and account data",
+	auth.ScopeUsersWrite:  "Change your profile, follows and account settings",
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Authorize {{.ClientName}} - Chirpy</title>
+</head>
+<body>
+<h1>Authorize {{.ClientName}}</h1>
+<p><strong>{{.ClientName}}</strong> would like to:</p>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{if .Error}}<p role="alert"><strong>{{.Error}}</strong></p>
+{{end}}<form method="post" action="/oauth/authorize">
+<input type="hidden" name="response_type" value="code">
+<input type="hidden" name="client_id" value="{{.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Scope}}">
+<input type="hidden" name="state" value="{{.State}}">
+<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="S256">
+<p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+<p><label>Authenticator or recovery code, if you use two-factor authentication <input type="text" name="code" autocomplete="one-time-code"></label></p>
+<p>
+<button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+</p>
+</form>
+<p>Either way you will be sent back to {{.RedirectHost}}.</p>
+</body>
+</html>
+`))
+
+var authorizeErrorTemplate = template.Must(template.New("authorize_error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Authorization failed - Chirpy</title>
+</head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+type consentPage struct {
+	ClientName    string
+	ClientID      string
+	RedirectURI   string
+	RedirectHost  string
+	Scope         string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+	Email         string
+	Error         string
+}
+
+// renderConsent shows the user what a client is asking for and lets them
+// log in to allow it.
+func renderConsent(w http.ResponseWriter, code int, authRequest authorizationRequest, email, errorMessage string) {
+	page := consentPage{
+		ClientName:    authRequest.client.Name,
+		ClientID:      authRequest.client.ID.String(),
+		RedirectURI:   authRequest.redirectURI,
+		Scope:         strings.Join(authRequest.scopes, " "),
+		State:         authRequest.state,
+		CodeChallenge: authRequest.codeChallenge,
+		Email:         email,
+		Error:         errorMessage,
+	}
+	if redirectURL, err := url.Parse(authRequest.redirectURI); err == nil {
+		page.RedirectHost = redirectURL.Host
+		if page.RedirectHost == "" {
+			page.RedirectHost = redirectURL.Scheme + ":"
+		}
+	}
+	for _, scope := range authRequest.scopes {
+		page.Scopes = append(page.Scopes, scopeDescriptions[scope])
+	}
+
+	renderHTML(w, code, consentTemplate, page)
+}
+
+// renderAuthorizeError is used when the client or redirect URI can't be
+// trusted, so the error can't be sent back to the client.
+func renderAuthorizeError(w http.ResponseWriter, code int, msg string) {
+	renderHTML(w, code, authorizeErrorTemplate, msg)
+}
+
+// renderHTML writes a page that may not be framed, so the consent screen
+// can't be overlaid by another site to trick the user into clicking Allow.
+func renderHTML(w http.ResponseWriter, code int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(code)
+	err := tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error rendering %s page: %s", tmpl.Name(), err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor is the keyset position a page starts after. It is handed to
+// clients as an opaque base64 string so its contents can change freely.
+type pageCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+	Rank      float32   `json:"rank,omitempty"`
+	Backward  bool      `json:"backward,omitempty"`
+}
+
+type pageRequest struct {
+	limit  int
+	cursor *pageCursor
+}
+
+type page[T any] struct {
+	items      []T
+	nextCursor string
+	prevCursor string
+}
+
+func getPageRequest(r *http.Request) (pageRequest, responseError) {
+	pageReq := pageRequest{limit: defaultPageLimit}
+
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		limit, err := strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageRequest{}, responseError{code: 400, err: fmt.Errorf("limit must be between 1 and %d", maxPageLimit)}
+		}
+		pageReq.limit = limit
+	}
+
+	if cursorString := r.URL.Query().Get("cursor"); cursorString != "" {
+		cursor, err := decodeCursor(cursorString)
+		if err != nil {
+			return pageRequest{}, responseError{code: 400, err: fmt.Errorf("invalid cursor")}
+		}
+		pageReq.cursor = &cursor
+	}
+
+	return pageReq, responseError{}
+}
+
+// fetchLimit asks for one extra row so we know whether another page exists.
+func (p pageRequest) fetchLimit() int32 {
+	return int32(p.limit + 1)
+}
+
+func (p pageRequest) backward() bool {
+	return p.cursor != nil && p.cursor.Backward
+}
+
+// paginate trims the rows returned by a keyset query into a page. Rows for
+// a backward request arrive in reverse display order and are flipped here.
+func paginate[T any](rows []T, pageReq pageRequest, cursorFor func(T) pageCursor) page[T] {
+	hasMore := len(rows) > pageReq.limit
+	if hasMore {
+		rows = rows[:pageReq.limit]
+	}
+
+	if pageReq.backward() {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	result := page[T]{items: rows}
+	if len(rows) == 0 {
+		return result
+	}
+
+	first := cursorFor(rows[0])
+	first.Backward = true
+	last := cursorFor(rows[len(rows)-1])
+
+	if pageReq.backward() {
+		result.nextCursor = encodeCursor(last)
+		if hasMore {
+			result.prevCursor = encodeCursor(first)
+		}
+		return result
+	}
+
+	if hasMore {
+		result.nextCursor = encodeCursor(last)
+	}
+	if pageReq.cursor != nil {
+		result.prevCursor = encodeCursor(first)
+	}
+	return result
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursorString string) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursorString)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	var cursor pageCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	if cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return pageCursor{}, fmt.Errorf("incomplete cursor")
+	}
+	return cursor, nil
+}
+
+// setLinkHeader advertises the neighbouring pages using the same query
+// string the client sent, with only the cursor swapped out.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, nextCursor, prevCursor string) {
+	var links []string
+	for _, link := range []struct {
+		rel    string
+		cursor string
+	}{
+		{"next", nextCursor},
+		{"prev", prevCursor},
+	} {
+		if link.cursor == "" {
+			continue
+		}
+		query := r.URL.Query()
+		query.Set("cursor", link.cursor)
+		pageURL := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", pageURL.String(), link.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []pageCursor{
+		{CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC), ID: uuid.New()},
+		{CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), ID: uuid.New(), Backward: true},
+		{CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), ID: uuid.New(), Rank: 0.25},
+	}
+	for _, cursor := range cursors {
+		got, err := decodeCursor(encodeCursor(cursor))
+		if err != nil {
+			t.Fatalf("decodeCursor(encodeCursor(%+v)) returned error: %v", cursor, err)
+		}
+		if !got.CreatedAt.Equal(cursor.CreatedAt) || got.ID != cursor.ID || got.Rank != cursor.Rank || got.Backward != cursor.Backward {
+			t.Errorf("round trip of %+v = %+v", cursor, got)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsBadInput(t *testing.T) {
+	for _, cursorString := range []string{
+		"not base64!",
+		encodeCursor(pageCursor{})[:4] + "@@",
+		"bm90IGpzb24",
+		encodeCursor(pageCursor{ID: uuid.New()}),
+		encodeCursor(pageCursor{CreatedAt: time.Now()}),
+	} {
+		if _, err := decodeCursor(cursorString); err == nil {
+			t.Errorf("decodeCursor(%q) returned no error", cursorString)
+		}
+	}
+}
+
+func TestGetPageRequest(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Now().UTC(), ID: uuid.New()}
+	tests := []struct {
+		query      string
+		wantLimit  int
+		wantCursor bool
+		wantErr    bool
+	}{
+		{query: "", wantLimit: defaultPageLimit},
+		{query: "limit=1", wantLimit: 1},
+		{query: "limit=100", wantLimit: maxPageLimit},
+		{query: "limit=5&cursor=" + encodeCursor(cursor), wantLimit: 5, wantCursor: true},
+		{query: "limit=0", wantErr: true},
+		{query: "limit=101", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+		{query: "cursor=garbage", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			pageReq, resErr := getPageRequest(httptest.NewRequest("GET", "/api/chirps?"+tt.query, nil))
+			if tt.wantErr {
+				if resErr.err == nil || resErr.code != 400 {
+					t.Fatalf("getPageRequest(%q) = %+v, want a 400", tt.query, resErr)
+				}
+				return
+			}
+			if resErr.err != nil {
+				t.Fatalf("getPageRequest(%q) returned error: %v", tt.query, resErr.err)
+			}
+			if pageReq.limit != tt.wantLimit || (pageReq.cursor != nil) != tt.wantCursor {
+				t.Errorf("getPageRequest(%q) = %+v", tt.query, pageReq)
+			}
+		})
+	}
+}
+
+type pageItem struct {
+	createdAt time.Time
+	id        uuid.UUID
+}
+
+func pageItemCursor(item pageItem) pageCursor {
+	return pageCursor{CreatedAt: item.createdAt, ID: item.id}
+}
+
+// compare orders an item against a cursor by (created_at, id), as the
+// row comparisons in the queries do.
+func (a pageItem) compare(b pageCursor) int {
+	if c := a.createdAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.id[:], b.ID[:])
+}
+
+// keysetQuery does what the Descending and Ascending queries do in SQL:
+// newest first after the cursor, or oldest first before a backward one.
+func keysetQuery(newestFirst []pageItem, pageReq pageRequest) []pageItem {
+	var rows []pageItem
+	if pageReq.backward() {
+		for i := len(newestFirst) - 1; i >= 0; i-- {
+			if newestFirst[i].compare(*pageReq.cursor) > 0 {
+				rows = append(rows, newestFirst[i])
+			}
+		}
+	} else {
+		for _, item := range newestFirst {
+			if pageReq.cursor == nil || item.compare(*pageReq.cursor) < 0 {
+				rows = append(rows, item)
+			}
+		}
+	}
+	return rows[:min(len(rows), int(pageReq.fetchLimit()))]
+}
+
+func TestPaginate(t *testing.T) {
+	// Seven items, newest first. Two pairs share a timestamp so the id
+	// has to break the tie.
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var newestFirst []pageItem
+	for _, minutes := range []int{6, 5, 5, 4, 3, 3, 1} {
+		newestFirst = append(newestFirst, pageItem{createdAt: base.Add(time.Duration(minutes) * time.Minute), id: uuid.New()})
+	}
+	slices.SortFunc(newestFirst, func(a, b pageItem) int {
+		return b.compare(pageItemCursor(a))
+	})
+
+	fetch := func(limit int, cursorString string) page[pageItem] {
+		t.Helper()
+		pageReq := pageRequest{limit: limit}
+		if cursorString != "" {
+			cursor, err := decodeCursor(cursorString)
+			if err != nil {
+				t.Fatalf("decodeCursor returned error: %v", err)
+			}
+			pageReq.cursor = &cursor
+		}
+		return paginate(keysetQuery(newestFirst, pageReq), pageReq, pageItemCursor)
+	}
+
+	// Forwards from the start, three at a time.
+	var pages []page[pageItem]
+	cursor := ""
+	for {
+		p := fetch(3, cursor)
+		pages = append(pages, p)
+		if p.nextCursor == "" {
+			break
+		}
+		if len(pages) > len(newestFirst) {
+			t.Fatal("paging forwards never ended")
+		}
+		cursor = p.nextCursor
+	}
+
+	wantPages := [][]pageItem{newestFirst[0:3], newestFirst[3:6], newestFirst[6:7]}
+	if len(pages) != len(wantPages) {
+		t.Fatalf("got %d pages forwards, want %d", len(pages), len(wantPages))
+	}
+	for i, p := range pages {
+		if !slices.Equal(p.items, wantPages[i]) {
+			t.Errorf("forward page %d = %v, want %v", i, p.items, wantPages[i])
+		}
+	}
+	if pages[0].prevCursor != "" {
+		t.Error("first page has a prev cursor")
+	}
+	if pages[1].prevCursor == "" || pages[2].prevCursor == "" {
+		t.Error("later pages are missing a prev cursor")
+	}
+
+	// Back from the last page, which must land on the same pages.
+	prev := fetch(3, pages[2].prevCursor)
+	if !slices.Equal(prev.items, wantPages[1]) {
+		t.Errorf("back from the last page = %v, want %v", prev.items, wantPages[1])
+	}
+	if prev.nextCursor == "" || prev.prevCursor == "" {
+		t.Errorf("middle page fetched backwards has cursors %q, %q", prev.nextCursor, prev.prevCursor)
+	}
+
+	first := fetch(3, prev.prevCursor)
+	if !slices.Equal(first.items, wantPages[0]) {
+		t.Errorf("back to the first page = %v, want %v", first.items, wantPages[0])
+	}
+	if first.prevCursor != "" {
+		t.Error("first page fetched backwards has a prev cursor")
+	}
+	if first.nextCursor == "" {
+		t.Fatal("first page fetched backwards has no next cursor")
+	}
+
+	again := fetch(3, first.nextCursor)
+	if !slices.Equal(again.items, wantPages[1]) {
+		t.Errorf("forwards again from the first page = %v, want %v", again.items, wantPages[1])
+	}
+
+	// A backward page that exactly fills the limit stops at the start.
+	exact := fetch(2, fetch(2, fetch(2, "").nextCursor).prevCursor)
+	if !slices.Equal(exact.items, newestFirst[0:2]) || exact.prevCursor != "" {
+		t.Errorf("exact backward page = %v with prev cursor %q", exact.items, exact.prevCursor)
+	}
+}
+
+func TestPaginateEmpty(t *testing.T) {
+	p := paginate([]pageItem{}, pageRequest{limit: 3}, pageItemCursor)
+	if len(p.items) != 0 || p.nextCursor != "" || p.prevCursor != "" {
+		t.Errorf("empty page = %+v", p)
+	}
+}
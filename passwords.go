@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultPasswordMinLength      = 8
+	defaultPasswordMinEntropyBits = 40
+
+	// The argon2id defaults are OWASP's recommended minimum.
+	defaultArgon2Memory      = 19 * 1024
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+)
+
+// loadPasswordPolicy reads the rules for new passwords from
+// PASSWORD_MIN_LENGTH, PASSWORD_MIN_ENTROPY_BITS and, to reject breached
+// passwords, BREACHED_PASSWORDS_PATH. The maximum length is whatever the
+// hasher can take.
+func loadPasswordPolicy(hasher auth.Hasher) (auth.PasswordPolicy, error) {
+	policy := auth.PasswordPolicy{
+		MinLength:      defaultPasswordMinLength,
+		MaxLength:      hasher.MaxPasswordLength(),
+		MinEntropyBits: defaultPasswordMinEntropyBits,
+	}
+
+	if minLength := os.Getenv("PASSWORD_MIN_LENGTH"); minLength != "" {
+		length, err := strconv.Atoi(minLength)
+		if err != nil || length < 1 {
+			return auth.PasswordPolicy{}, fmt.Errorf("PASSWORD_MIN_LENGTH must be a positive number")
+		}
+		policy.MinLength = length
+	}
+
+	if minEntropy := os.Getenv("PASSWORD_MIN_ENTROPY_BITS"); minEntropy != "" {
+		bits, err := strconv.ParseFloat(minEntropy, 64)
+		if err != nil || bits < 0 {
+			return auth.PasswordPolicy{}, fmt.Errorf("PASSWORD_MIN_ENTROPY_BITS must be a number of bits")
+		}
+		policy.MinEntropyBits = bits
+	}
+
+	if breachedPath := os.Getenv("BREACHED_PASSWORDS_PATH"); breachedPath != "" {
+		breached, err := auth.LoadBreachedPasswords(breachedPath)
+		if err != nil {
+			return auth.PasswordPolicy{}, fmt.Errorf("cannot load breached passwords: %w", err)
+		}
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+// loadPasswordHasher picks how new password hashes are made from
+// PASSWORD_HASH_ALGORITHM, argon2id by default or bcrypt. argon2id is
+// tuned with ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and ARGON2_PARALLELISM,
+// bcrypt with BCRYPT_COST. Existing hashes made any other way are
+// replaced as their users log in.
+func loadPasswordHasher() (auth.Hasher, error) {
+	switch os.Getenv("PASSWORD_HASH_ALGORITHM") {
+	case "", "argon2id":
+		memory, err := envUint("ARGON2_MEMORY_KIB", defaultArgon2Memory, 8, math.MaxUint32)
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := envUint("ARGON2_ITERATIONS", defaultArgon2Iterations, 1, math.MaxUint32)
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := envUint("ARGON2_PARALLELISM", defaultArgon2Parallelism, 1, math.MaxUint8)
+		if err != nil {
+			return nil, err
+		}
+		hasher := auth.Argon2idHasher{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+		}
+		err = hasher.Validate()
+		if err != nil {
+			return nil, err
+		}
+		return hasher, nil
+	case "bcrypt":
+		cost, err := envUint("BCRYPT_COST", uint64(bcrypt.DefaultCost), uint64(bcrypt.MinCost), uint64(bcrypt.MaxCost))
+		if err != nil {
+			return nil, err
+		}
+		return auth.BcryptHasher{Cost: int(cost)}, nil
+	default:
+		return nil, fmt.Errorf("PASSWORD_HASH_ALGORITHM must be argon2id or bcrypt")
+	}
+}
+
+func envUint(name string, defaultValue, minValue, maxValue uint64) (uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || parsed < minValue || parsed > maxValue {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, minValue, maxValue)
+	}
+	return parsed, nil
+}
+
+// checkPasswordPolicy is for passwords being set; email is the address
+// the account will have.
+func (cfg *apiConfig) checkPasswordPolicy(password, email string) responseError {
+	err := cfg.passwordPolicy.Check(password, email)
+	var weakErr *auth.WeakPasswordError
+	if errors.As(err, &weakErr) {
+		return responseError{code: 400, err: weakErr}
+	}
+	if err != nil {
+		log.Printf("Error checking password policy: %s", err)
+		return responseError{code: 500, err: fmt.Errorf("something went wrong")}
+	}
+	return responseError{}
+}
+
+func (cfg *apiConfig) hashPassword(password string) (string, error) {
+	return cfg.passwordHasher.Hash(password)
+}
+
+// revokeCredentials is for when a user's password has changed, since
+// whoever knew the old one may have used it to sign in or make API keys.
+// Every session but keepSession ends, every API key is revoked and the
+// access tokens already handed out stop working.
+func (cfg *apiConfig) revokeCredentials(ctx context.Context, userID uuid.UUID, keepSession uuid.NullUUID) (database.User, error) {
+	var err error
+	if keepSession.Valid {
+		_, err = cfg.db.RevokeOtherSessions(ctx, database.RevokeOtherSessionsParams{
+			UserID:   userID,
+			FamilyID: keepSession.UUID,
+		})
+	} else {
+		err = cfg.db.RevokeRefreshTokensForUser(ctx, userID)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = cfg.db.RevokeAPIKeysForUser(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return cfg.db.BumpTokenVersion(ctx, userID)
+}
+
+// verifyPassword checks a user's password. While the password is at hand,
+// a hash made with outdated settings is replaced, so the user base moves
+// to new settings without anyone having to reset their password.
+func (cfg *apiConfig) verifyPassword(ctx context.Context, dbUser database.User, password string) error {
+	err := auth.CheckPasswordHash(password, dbUser.HashedPassword)
+	if err != nil {
+		return err
+	}
+
+	if cfg.passwordHasher.NeedsRehash(dbUser.HashedPassword) {
+		hashedPassword, err := cfg.hashPassword(password)
+		if err == nil {
+			// Only replaces the hash that was checked, so a password
+			// changed in the meantime is left alone.
+			err = cfg.db.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+				NewHashedPassword: hashedPassword,
+				ID:                dbUser.ID,
+				OldHashedPassword: dbUser.HashedPassword,
+			})
+		}
+		if err != nil {
+			log.Printf("Error rehashing password for user %s: %s", dbUser.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, now(), $6)
+RETURNING *;
+
+-- name: GetAPIKey :one
+SELECT *
+FROM api_keys
+WHERE id = $1;
+
+-- name: ListAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE user_id = $1
+AND revoked_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = sqlc.arg('id')::uuid
+AND user_id = sqlc.arg('user_id')::uuid
+AND revoked_at IS NULL;
+
+-- name: RevokeAPIKeysForUser :exec
+UPDATE api_keys
+SET revoked_at = now()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
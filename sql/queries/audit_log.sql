@@ -0,0 +1,23 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO audit_log (id, actor_id, action, target_user_id, target_chirp_id, details, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, now());
+
+-- name: ListAuditLogDescending :many
+SELECT *
+FROM audit_log
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id')::uuid)
+AND (sqlc.narg('target_user_id')::uuid IS NULL OR target_user_id = sqlc.narg('target_user_id')::uuid)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListAuditLogAscending :many
+SELECT *
+FROM audit_log
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id')::uuid)
+AND (sqlc.narg('target_user_id')::uuid IS NULL OR target_user_id = sqlc.narg('target_user_id')::uuid)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
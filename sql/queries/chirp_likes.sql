@@ -0,0 +1,37 @@
+-- name: LikeChirp :exec
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM chirp_likes
+WHERE chirp_id = $1
+AND user_id = $2;
+
+-- name: ListChirpLikersDescending :many
+SELECT sqlc.embed(users), chirp_likes.created_at AS liked_at
+FROM chirp_likes
+JOIN users ON users.id = chirp_likes.user_id
+WHERE chirp_likes.chirp_id = sqlc.arg('chirp_id')::uuid
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (chirp_likes.created_at, chirp_likes.user_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirp_likes.created_at DESC, chirp_likes.user_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpLikersAscending :many
+SELECT sqlc.embed(users), chirp_likes.created_at AS liked_at
+FROM chirp_likes
+JOIN users ON users.id = chirp_likes.user_id
+WHERE chirp_likes.chirp_id = sqlc.arg('chirp_id')::uuid
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (chirp_likes.created_at, chirp_likes.user_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirp_likes.created_at ASC, chirp_likes.user_id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpLikeStats :many
+SELECT chirp_id,
+	COUNT(*) AS like_count,
+	COALESCE(BOOL_OR(user_id = sqlc.narg('viewer_id')::uuid), FALSE)::boolean AS liked_by_me
+FROM chirp_likes
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY chirp_id;
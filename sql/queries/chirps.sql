@@ -1,11 +1,31 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (gen_random_uuid(), $1, $2, $3, $4)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, thread_id, kind, referenced_chirp_id)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
--- name: GetAllChirps :many
+-- name: ListChirpsAscending :many
 SELECT * FROM chirps
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL
+AND (cardinality(sqlc.arg('author_ids')::uuid[]) = 0 OR user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+AND NOT (user_id = ANY(sqlc.arg('exclude_author_ids')::uuid[]))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since')::timestamp)
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until')::timestamp)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDescending :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (cardinality(sqlc.arg('author_ids')::uuid[]) = 0 OR user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+AND NOT (user_id = ANY(sqlc.arg('exclude_author_ids')::uuid[]))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since')::timestamp)
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until')::timestamp)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
 
 -- name: GetAllChirpsByAuthor :many
 SELECT * FROM chirps
@@ -16,7 +36,77 @@ ORDER BY created_at ASC;
 SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: GetChirpReplies :many
+SELECT * FROM chirps
+WHERE in_reply_to = sqlc.arg('chirp_id')::uuid
+ORDER BY created_at ASC, id ASC;
+
+-- name: GetChirpThread :many
+SELECT * FROM chirps
+WHERE id = sqlc.arg('thread_id') OR thread_id = sqlc.arg('thread_id')
+ORDER BY created_at ASC, id ASC;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: GetRechirpStats :many
+SELECT referenced_chirp_id::uuid AS chirp_id,
+	COUNT(*) FILTER (WHERE kind = 'rechirp') AS rechirp_count,
+	COUNT(*) FILTER (WHERE kind = 'quote') AS quote_count,
+	COALESCE(BOOL_OR(kind = 'rechirp' AND user_id = sqlc.narg('viewer_id')::uuid), FALSE)::boolean AS rechirped_by_me
+FROM chirps
+WHERE referenced_chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+AND deleted_at IS NULL
+GROUP BY referenced_chirp_id;
+
+-- name: ChirpHasDependents :one
+SELECT EXISTS (
+	SELECT 1 FROM chirps
+	WHERE in_reply_to = sqlc.arg('chirp_id')::uuid
+	OR (referenced_chirp_id = sqlc.arg('chirp_id')::uuid AND kind = 'quote')
+);
+
+-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps
+WHERE referenced_chirp_id = sqlc.arg('chirp_id')::uuid
+AND kind = 'rechirp';
+
+-- name: TombstoneChirpByID :one
+UPDATE chirps
+SET body = '',
+	deleted_at = now(),
+	updated_at = now()
+WHERE id = $1
+RETURNING *;
+
 -- name: DeleteChirpByID :one
 DELETE FROM chirps
 WHERE id = $1
 RETURNING *;
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps), ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query')::text))::real AS rank
+FROM chirps
+WHERE deleted_at IS NULL
+AND body_tsv @@ to_tsquery('english', sqlc.arg('query')::text)
+AND (cardinality(sqlc.arg('author_ids')::uuid[]) = 0 OR user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+AND NOT (user_id = ANY(sqlc.arg('exclude_author_ids')::uuid[]))
+AND (sqlc.narg('cursor_rank')::real IS NULL
+	OR (ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query')::text)), created_at, id)
+	< (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: SearchChirpsReverse :many
+SELECT sqlc.embed(chirps), ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query')::text))::real AS rank
+FROM chirps
+WHERE deleted_at IS NULL
+AND body_tsv @@ to_tsquery('english', sqlc.arg('query')::text)
+AND (cardinality(sqlc.arg('author_ids')::uuid[]) = 0 OR user_id = ANY(sqlc.arg('author_ids')::uuid[]))
+AND NOT (user_id = ANY(sqlc.arg('exclude_author_ids')::uuid[]))
+AND (sqlc.narg('cursor_rank')::real IS NULL
+	OR (ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query')::text)), created_at, id)
+	> (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank ASC, created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
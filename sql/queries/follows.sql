@@ -0,0 +1,79 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1
+AND followee_id = $2;
+
+-- name: ListFollowersDescending :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg('user_id')::uuid
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (follows.created_at, follows.follower_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListFollowersAscending :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg('user_id')::uuid
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (follows.created_at, follows.follower_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY follows.created_at ASC, follows.follower_id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListFollowingDescending :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('user_id')::uuid
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (follows.created_at, follows.followee_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListFollowingAscending :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('user_id')::uuid
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (follows.created_at, follows.followee_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY follows.created_at ASC, follows.followee_id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTimelineDescending :many
+SELECT timeline.* FROM follows
+CROSS JOIN LATERAL (
+	SELECT * FROM chirps
+	WHERE chirps.user_id = follows.followee_id
+	AND chirps.deleted_at IS NULL
+	AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+		OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+	ORDER BY chirps.created_at DESC, chirps.id DESC
+	LIMIT sqlc.arg('page_limit')
+) AS timeline
+WHERE follows.follower_id = sqlc.arg('user_id')::uuid
+ORDER BY timeline.created_at DESC, timeline.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListTimelineAscending :many
+SELECT timeline.* FROM follows
+CROSS JOIN LATERAL (
+	SELECT * FROM chirps
+	WHERE chirps.user_id = follows.followee_id
+	AND chirps.deleted_at IS NULL
+	AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+		OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+	ORDER BY chirps.created_at ASC, chirps.id ASC
+	LIMIT sqlc.arg('page_limit')
+) AS timeline
+WHERE follows.follower_id = sqlc.arg('user_id')::uuid
+ORDER BY timeline.created_at ASC, timeline.id ASC
+LIMIT sqlc.arg('page_limit');
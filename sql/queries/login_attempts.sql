@@ -0,0 +1,29 @@
+-- name: GetLoginAttempts :one
+SELECT *
+FROM login_attempts
+WHERE key = $1;
+
+-- name: AddLoginFailure :one
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES (sqlc.arg('key')::text, 1, sqlc.arg('failed_at')::timestamp)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+		WHEN login_attempts.last_failure_at < sqlc.arg('window_start')::timestamp THEN 1
+		ELSE login_attempts.failures + 1
+	END,
+	last_failure_at = sqlc.arg('failed_at')::timestamp
+RETURNING *;
+
+-- name: LockLoginAttempts :exec
+UPDATE login_attempts
+SET locked_until = GREATEST(locked_until, sqlc.arg('locked_until')::timestamp)
+WHERE key = sqlc.arg('key')::text;
+
+-- name: ResetLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: DeleteStaleLoginAttempts :execrows
+DELETE FROM login_attempts
+WHERE last_failure_at < sqlc.arg('window_start')::timestamp
+AND (locked_until IS NULL OR locked_until < now());
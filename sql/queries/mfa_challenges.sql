@@ -0,0 +1,28 @@
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (id, user_id, token_hash, token_version, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetMFAChallenge :one
+SELECT *
+FROM mfa_challenges
+WHERE id = $1;
+
+-- name: AddMFAChallengeAttempt :execrows
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = sqlc.arg('id')::uuid
+AND used_at IS NULL
+AND expires_at > now()
+AND attempts < sqlc.arg('max_attempts')::int;
+
+-- name: UseMFAChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = now()
+WHERE id = $1
+AND used_at IS NULL
+AND expires_at > now();
+
+-- name: DeleteStaleMFAChallenges :exec
+DELETE FROM mfa_challenges
+WHERE user_id = $1
+AND (used_at IS NOT NULL OR expires_at <= now());
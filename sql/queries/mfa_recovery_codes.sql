@@ -0,0 +1,19 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+VALUES (gen_random_uuid(), $1, $2, now());
+
+-- name: GetUnusedRecoveryCodes :many
+SELECT *
+FROM mfa_recovery_codes
+WHERE user_id = $1
+AND used_at IS NULL;
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = now()
+WHERE id = $1
+AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
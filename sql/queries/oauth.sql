@@ -0,0 +1,37 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, scopes, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, now(), now())
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListOAuthClients :many
+SELECT *
+FROM oauth_clients
+ORDER BY created_at ASC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (
+	id, code_hash, client_id, user_id, redirect_uri, scope, code_challenge,
+	created_at, expires_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+
+-- name: GetAuthorizationCode :one
+SELECT *
+FROM oauth_authorization_codes
+WHERE id = $1;
+
+-- name: UseAuthorizationCode :execrows
+UPDATE oauth_authorization_codes
+SET used_at = now()
+WHERE id = $1
+AND used_at IS NULL
+AND expires_at > now();
@@ -1,17 +1,82 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at) 
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO refresh_tokens (
+	id, token_hash, created_at, updated_at, user_id, expires_at, family_id,
+	user_agent, ip_address, device_name, last_used_at, client_id, scope
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 RETURNING *;
 
 -- name: FindRefreshToken :one
 SELECT *
 FROM refresh_tokens
-WHERE token = $1
-AND revoked_at IS NULL 
-AND expires_at > now();
+WHERE id = $1;
 
--- name: RevokeRefreshToken :one
+-- name: RotateRefreshToken :one
 UPDATE refresh_tokens
-SET revoked_at = now()
-WHERE token = $1
-RETURNING *;
\ No newline at end of file
+SET revoked_at = now(),
+	updated_at = now(),
+	replaced_by = sqlc.arg('replaced_by')::uuid
+WHERE id = sqlc.arg('id')::uuid
+AND revoked_at IS NULL
+AND expires_at > now()
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE family_id = $1
+AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensForUser :many
+SELECT *
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListSessions :many
+SELECT refresh_tokens.*,
+	(
+		SELECT min(family.created_at)
+		FROM refresh_tokens AS family
+		WHERE family.family_id = refresh_tokens.family_id
+	)::timestamp AS signed_in_at
+FROM refresh_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > now()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE family_id = sqlc.arg('family_id')::uuid
+AND user_id = sqlc.arg('user_id')::uuid
+AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = now(),
+	updated_at = now()
+WHERE user_id = sqlc.arg('user_id')::uuid
+AND family_id <> sqlc.arg('family_id')::uuid
+AND revoked_at IS NULL;
+
+-- name: SessionRevoked :one
+-- A session has ended once one of its tokens was revoked without being
+-- replaced; rotating a token always records what replaced it.
+SELECT EXISTS (
+	SELECT 1
+	FROM refresh_tokens
+	WHERE family_id = $1
+	AND revoked_at IS NOT NULL
+	AND replaced_by IS NULL
+);
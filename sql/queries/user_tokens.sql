@@ -0,0 +1,30 @@
+-- name: CreateUserToken :exec
+INSERT INTO user_tokens (id, user_id, purpose, token_hash, email, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetUserToken :one
+SELECT *
+FROM user_tokens
+WHERE id = $1;
+
+-- name: UseUserToken :execrows
+UPDATE user_tokens
+SET used_at = now()
+WHERE id = $1
+AND used_at IS NULL
+AND expires_at > now();
+
+-- name: DeleteSupersededUserTokens :exec
+DELETE FROM user_tokens
+WHERE user_id = sqlc.arg('user_id')::uuid
+AND purpose = sqlc.arg('purpose')::text
+AND id NOT IN (
+	SELECT id
+	FROM user_tokens
+	WHERE user_id = sqlc.arg('user_id')::uuid
+	AND purpose = sqlc.arg('purpose')::text
+	AND used_at IS NULL
+	AND expires_at > now()
+	ORDER BY created_at DESC
+	LIMIT sqlc.arg('keep')::int
+);
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
 	gen_random_uuid(),
 	$1,
 	$2,
 	$3,
-	$4
+	$4,
+	$5
 ) RETURNING *;
 
 -- name: GetUserByEmail :one
@@ -14,15 +15,205 @@ FROM users
 WHERE email = $1;
 
 
--- name: UpdateUserEmailAndPassword :one
+-- name: AddChirpyRedByID :one
 UPDATE users
-SET email = $2,
-	hashed_password = $3
+SET is_chirpy_red = TRUE
 WHERE id = $1
 RETURNING *;
 
--- name: AddChirpyRedByID :one
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT *
+FROM users
+WHERE lower(handle) = lower(sqlc.arg('handle')::text);
+
+-- name: UpdateUser :one
 UPDATE users
-SET is_chirpy_red = TRUE
+SET email = COALESCE(sqlc.narg('email')::text, email),
+	email_verified_at = CASE
+		WHEN sqlc.narg('email')::text <> email THEN NULL
+		ELSE email_verified_at
+	END,
+	hashed_password = COALESCE(sqlc.narg('hashed_password')::text, hashed_password),
+	handle = COALESCE(sqlc.narg('handle')::text, handle),
+	display_name = COALESCE(sqlc.narg('display_name')::text, display_name),
+	bio = COALESCE(sqlc.narg('bio')::text, bio),
+	location = COALESCE(sqlc.narg('location')::text, location),
+	updated_at = now()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = now(),
+	updated_at = now()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+	updated_at = now()
+WHERE id = $1
+AND deletion_requested_at IS NOT NULL;
+
+-- name: ListUsersPendingDeletion :many
+SELECT id
+FROM users
+WHERE deletion_requested_at < sqlc.arg('requested_before')::timestamp;
+
+-- name: PurgeUser :exec
+WITH deleted_refresh_tokens AS (
+	DELETE FROM refresh_tokens WHERE user_id = sqlc.arg('id')::uuid
+), deleted_api_keys AS (
+	DELETE FROM api_keys WHERE user_id = sqlc.arg('id')::uuid
+), deleted_authorization_codes AS (
+	DELETE FROM oauth_authorization_codes WHERE user_id = sqlc.arg('id')::uuid
+), deleted_user_tokens AS (
+	DELETE FROM user_tokens WHERE user_id = sqlc.arg('id')::uuid
+), deleted_recovery_codes AS (
+	DELETE FROM mfa_recovery_codes WHERE user_id = sqlc.arg('id')::uuid
+), deleted_mfa_challenges AS (
+	DELETE FROM mfa_challenges WHERE user_id = sqlc.arg('id')::uuid
+), deleted_likes AS (
+	DELETE FROM chirp_likes WHERE user_id = sqlc.arg('id')::uuid
+), deleted_follows AS (
+	DELETE FROM follows WHERE follower_id = sqlc.arg('id')::uuid OR followee_id = sqlc.arg('id')::uuid
+)
+UPDATE users
+SET email = 'deleted-' || id,
+	hashed_password = '',
+	is_chirpy_red = FALSE,
+	handle = NULL,
+	display_name = '',
+	bio = '',
+	location = '',
+	deletion_requested_at = NULL,
+	token_version = token_version + 1,
+	totp_secret = NULL,
+	totp_enabled_at = NULL,
+	totp_last_counter = NULL,
+	email_verified_at = NULL,
+	suspended_at = NULL,
+	suspension_reason = '',
+	deleted_at = now(),
+	updated_at = now()
+WHERE id = sqlc.arg('id')::uuid;
+
+-- name: BumpTokenVersion :one
+UPDATE users
+SET token_version = token_version + 1,
+	updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserAccess :one
+SELECT email, email_verified_at, role, token_version, suspended_at
+FROM users
+WHERE id = $1;
+
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = sqlc.arg('totp_secret')::text,
+	totp_enabled_at = NULL,
+	totp_last_counter = NULL,
+	updated_at = now()
+WHERE id = sqlc.arg('id')::uuid;
+
+-- name: EnableTOTP :one
+UPDATE users
+SET totp_enabled_at = now(),
+	totp_last_counter = sqlc.arg('totp_last_counter')::bigint,
+	updated_at = now()
+WHERE id = sqlc.arg('id')::uuid
+AND totp_secret IS NOT NULL
+RETURNING *;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL,
+	totp_enabled_at = NULL,
+	totp_last_counter = NULL,
+	updated_at = now()
+WHERE id = $1;
+
+-- name: UseTOTPCounter :execrows
+UPDATE users
+SET totp_last_counter = sqlc.arg('totp_last_counter')::bigint
+WHERE id = sqlc.arg('id')::uuid
+AND (totp_last_counter IS NULL OR totp_last_counter < sqlc.arg('totp_last_counter')::bigint);
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = now(),
+	updated_at = now()
+WHERE id = sqlc.arg('id')::uuid
+AND email = sqlc.arg('email')::text
+RETURNING *;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg('new_hashed_password')::text
+WHERE id = sqlc.arg('id')::uuid
+AND hashed_password = sqlc.arg('old_hashed_password')::text;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = sqlc.arg('role')::text,
+	updated_at = now()
+WHERE id = sqlc.arg('id')::uuid
+RETURNING *;
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = COALESCE(suspended_at, now()),
+	suspension_reason = sqlc.arg('suspension_reason')::text,
+	updated_at = now()
+WHERE id = sqlc.arg('id')::uuid
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+	suspension_reason = '',
+	updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: LockUserPassword :exec
+UPDATE users
+SET hashed_password = '',
+	updated_at = now()
+WHERE id = $1;
+
+-- name: ListUsersDescending :many
+SELECT *
+FROM users
+WHERE (sqlc.narg('search')::text IS NULL
+	OR email ILIKE sqlc.narg('search')::text
+	OR handle ILIKE sqlc.narg('search')::text
+	OR display_name ILIKE sqlc.narg('search')::text)
+AND (sqlc.narg('role')::text IS NULL OR role = sqlc.narg('role')::text)
+AND (sqlc.narg('suspended')::boolean IS NULL OR (suspended_at IS NOT NULL) = sqlc.narg('suspended')::boolean)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListUsersAscending :many
+SELECT *
+FROM users
+WHERE (sqlc.narg('search')::text IS NULL
+	OR email ILIKE sqlc.narg('search')::text
+	OR handle ILIKE sqlc.narg('search')::text
+	OR display_name ILIKE sqlc.narg('search')::text)
+AND (sqlc.narg('role')::text IS NULL OR role = sqlc.narg('role')::text)
+AND (sqlc.narg('suspended')::boolean IS NULL OR (suspended_at IS NOT NULL) = sqlc.narg('suspended')::boolean)
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+	OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN thread_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to, created_at, id);
+CREATE INDEX chirps_thread_id_idx ON chirps (thread_id, created_at, id);
+
+-- +goose Down
+DROP INDEX chirps_thread_id_idx;
+DROP INDEX chirps_in_reply_to_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at,
+DROP COLUMN thread_id,
+DROP COLUMN in_reply_to;
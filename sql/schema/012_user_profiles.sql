@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT,
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN location TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (lower(handle));
+
+-- +goose Down
+DROP INDEX users_handle_lower_idx;
+
+ALTER TABLE users
+DROP COLUMN location,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
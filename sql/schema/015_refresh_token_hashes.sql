@@ -0,0 +1,22 @@
+-- +goose Up
+-- Existing tokens were stored in plain text and cannot be turned into the
+-- id.secret format, so every session is signed out.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_pkey,
+DROP COLUMN token,
+DROP COLUMN replaced_by,
+ADD COLUMN id UUID PRIMARY KEY,
+ADD COLUMN token_hash TEXT NOT NULL,
+ADD COLUMN replaced_by UUID;
+
+-- +goose Down
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_by,
+DROP COLUMN token_hash,
+DROP COLUMN id,
+ADD COLUMN token TEXT PRIMARY KEY,
+ADD COLUMN replaced_by TEXT;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT,
+ADD COLUMN totp_enabled_at TIMESTAMP,
+ADD COLUMN totp_last_counter BIGINT;
+
+CREATE TABLE mfa_recovery_codes (
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX mfa_recovery_codes_user_id_idx ON mfa_recovery_codes (user_id);
+
+-- +goose Down
+DROP TABLE mfa_recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN totp_last_counter,
+DROP COLUMN totp_enabled_at,
+DROP COLUMN totp_secret;
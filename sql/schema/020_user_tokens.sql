@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Accounts created before verification existed start out unverified
+-- like everyone else, since nobody has proved they own those addresses.
+
+CREATE TABLE user_tokens (
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	purpose TEXT NOT NULL CHECK (purpose IN ('password_reset', 'email_verification')),
+	token_hash TEXT NOT NULL,
+	email TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX user_tokens_user_id_idx ON user_tokens (user_id);
+
+-- +goose Down
+DROP TABLE user_tokens;
+
+ALTER TABLE users
+DROP COLUMN email_verified_at;
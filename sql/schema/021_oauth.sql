@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+	id UUID PRIMARY KEY,
+	name TEXT NOT NULL,
+	-- NULL for public clients, which can't keep a secret and rely on PKCE.
+	secret_hash TEXT,
+	redirect_uris TEXT[] NOT NULL,
+	scopes TEXT[] NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE oauth_authorization_codes (
+	id UUID PRIMARY KEY,
+	code_hash TEXT NOT NULL,
+	client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL,
+	code_challenge TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+-- Refresh tokens issued to a third-party client carry the client and the
+-- scopes the user granted it. Chirpy's own logins leave both NULL.
+ALTER TABLE refresh_tokens
+ADD COLUMN client_id UUID REFERENCES oauth_clients(id) ON DELETE CASCADE,
+ADD COLUMN scope TEXT;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN scope,
+DROP COLUMN client_id;
+
+DROP TABLE oauth_authorization_codes;
+
+DROP TABLE oauth_clients;
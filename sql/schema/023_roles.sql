@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+ADD COLUMN suspended_at TIMESTAMP,
+ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+-- Entries keep the ids of the users involved without foreign keys, so
+-- they outlive the accounts they are about.
+CREATE TABLE audit_log (
+	id UUID PRIMARY KEY,
+	actor_id UUID NOT NULL,
+	action TEXT NOT NULL,
+	target_user_id UUID,
+	target_chirp_id UUID,
+	details TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at, id);
+
+-- +goose Down
+DROP TABLE audit_log;
+
+ALTER TABLE users
+DROP COLUMN suspension_reason,
+DROP COLUMN suspended_at,
+DROP COLUMN role;
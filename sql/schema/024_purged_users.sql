@@ -0,0 +1,9 @@
+-- +goose Up
+-- A deleted account keeps a scrubbed row, so the tombstones of its chirps
+-- that others replied to or quoted still have an author.
+ALTER TABLE users
+ADD COLUMN deleted_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN deleted_at;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- thread_id only groups a conversation. As a foreign key it was cleared
+-- when the root chirp was deleted, turning every reply into a thread of
+-- its own, so it is a plain indexed column now.
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_thread_id_fkey;
+
+-- +goose Down
+UPDATE chirps
+SET thread_id = NULL
+WHERE thread_id IS NOT NULL
+AND NOT EXISTS (SELECT 1 FROM chirps AS roots WHERE roots.id = chirps.thread_id);
+
+ALTER TABLE chirps
+ADD CONSTRAINT chirps_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES chirps(id) ON DELETE SET NULL;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- 020 used to mark every existing address verified, including any that
+-- were signed up with an email the user doesn't own, such as one listed
+-- in ADMIN_EMAILS. Those rows have email_verified_at = created_at; real
+-- verifications always come later.
+UPDATE users
+SET email_verified_at = NULL
+WHERE email_verified_at = created_at;
+
+-- +goose Down
+-- The backfilled timestamps can't be told apart from NULL ones any more,
+-- so there is nothing to restore.
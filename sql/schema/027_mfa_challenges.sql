@@ -0,0 +1,19 @@
+-- +goose Up
+-- A challenge is handed out between the password and second factor steps
+-- of a login. It is kept here rather than in a signed token so it can be
+-- used up and can only take a few guesses.
+CREATE TABLE mfa_challenges (
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL,
+	token_version INTEGER NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX mfa_challenges_user_id_idx ON mfa_challenges (user_id);
+
+-- +goose Down
+DROP TABLE mfa_challenges;
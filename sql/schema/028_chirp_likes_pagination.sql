@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE INDEX chirp_likes_chirp_id_created_at_idx ON chirp_likes (chirp_id, created_at, user_id);
+
+-- Likes belong on the chirp a rechirp shares, so ones left on rechirps
+-- are moved there.
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+SELECT chirps.referenced_chirp_id, chirp_likes.user_id, min(chirp_likes.created_at)
+FROM chirp_likes
+JOIN chirps ON chirps.id = chirp_likes.chirp_id
+WHERE chirps.kind = 'rechirp'
+AND chirps.referenced_chirp_id IS NOT NULL
+GROUP BY chirps.referenced_chirp_id, chirp_likes.user_id
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+DELETE FROM chirp_likes
+USING chirps
+WHERE chirps.id = chirp_likes.chirp_id
+AND chirps.kind = 'rechirp';
+
+-- +goose Down
+DROP INDEX chirp_likes_chirp_id_created_at_idx;
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/KidMuon/chirpy/internal/auth"
+	"github.com/KidMuon/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	userTokenPasswordReset     = "password_reset"
+	userTokenEmailVerification = "email_verification"
+
+	passwordResetTokenDuration     = time.Hour
+	emailVerificationTokenDuration = 48 * time.Hour
+
+	// maxLiveUserTokens is how many unused links for the same purpose can
+	// work at once, so a link isn't broken by a later email that arrives
+	// first or not at all.
+	maxLiveUserTokens = 3
+)
+
+// issueUserToken makes a single-use token for a link emailed to the user.
+// Earlier links for the same purpose keep working until they expire or
+// more than maxLiveUserTokens are live. Like refresh tokens, only the id
+// and a hash of the secret are stored.
+func (cfg *apiConfig) issueUserToken(ctx context.Context, dbUser database.User, purpose string, expiresIn time.Duration) (string, error) {
+	err := cfg.db.DeleteSupersededUserTokens(ctx, database.DeleteSupersededUserTokensParams{
+		UserID:  dbUser.ID,
+		Purpose: purpose,
+		Keep:    maxLiveUserTokens - 1,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	tokenID := uuid.New()
+	err = cfg.db.CreateUserToken(ctx, database.CreateUserTokenParams{
+		ID:        tokenID,
+		UserID:    dbUser.ID,
+		Purpose:   purpose,
+		TokenHash: auth.HashRefreshToken(secret),
+		Email:     dbUser.Email,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(expiresIn),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return auth.FormatRefreshToken(tokenID, secret), nil
+}
+
+// redeemUserToken checks a token from an emailed link and uses it up, so
+// it cannot be redeemed twice.
+func (cfg *apiConfig) redeemUserToken(ctx context.Context, token, purpose string) (database.UserToken, error) {
+	dbUserToken, err := cfg.checkUserToken(ctx, token, purpose)
+	if err != nil {
+		return database.UserToken{}, err
+	}
+
+	err = cfg.useUserToken(ctx, dbUserToken)
+	if err != nil {
+		return database.UserToken{}, err
+	}
+
+	return dbUserToken, nil
+}
+
+// checkUserToken checks a token from an emailed link without using it up,
+// for when the rest of the request might still fail and the user should
+// be able to try the link again.
+func (cfg *apiConfig) checkUserToken(ctx context.Context, token, purpose string) (database.UserToken, error) {
+	tokenID, secret, err := auth.ParseRefreshToken(token)
+	if err != nil {
+		return database.UserToken{}, err
+	}
+
+	dbUserToken, err := cfg.db.GetUserToken(ctx, tokenID)
+	if err != nil {
+		return database.UserToken{}, err
+	}
+
+	err = auth.CheckRefreshTokenHash(secret, dbUserToken.TokenHash)
+	if err != nil {
+		return database.UserToken{}, err
+	}
+	if dbUserToken.Purpose != purpose {
+		return database.UserToken{}, fmt.Errorf("wrong token purpose")
+	}
+	if dbUserToken.UsedAt.Valid || !dbUserToken.ExpiresAt.After(time.Now()) {
+		return database.UserToken{}, fmt.Errorf("token expired or already used")
+	}
+
+	return dbUserToken, nil
+}
+
+// useUserToken marks a checked token used. Only one request can do so,
+// even if several checked the token at the same time.
+func (cfg *apiConfig) useUserToken(ctx context.Context, dbUserToken database.UserToken) error {
+	used, err := cfg.db.UseUserToken(ctx, dbUserToken.ID)
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return fmt.Errorf("token expired or already used")
+	}
+	return nil
+}